@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// findAccommodation returns the accommodation profile of a student, or nil if
+// they don't have one.
+func findAccommodation(app core.App, canvasStudentId int) (*core.Record, error) {
+	accommodation, err := app.FindFirstRecordByFilter(
+		"accommodations",
+		"canvas_student_id = {:student}",
+		dbx.Params{"student": canvasStudentId},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return accommodation, err
+}
+
+// accommodatedDuration is how many minutes a student with the accommodation
+// gets for a test: its duration times their multiplier, plus their breaks.
+func accommodatedDuration(testMins float64, accommodation *core.Record) float64 {
+	multiplier := accommodation.GetFloat("time_multiplier")
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	// partial minutes would leave bookings off the minute
+	return math.Ceil(testMins*multiplier) + accommodation.GetFloat("break_minutes")
+}
+
+// applyAccommodations derives a new enrollment's duration from its test,
+// extended for the student's accommodation profile if they have one, and
+// adds the profile's seating needs to its seat requirements. A duration given
+// with the enrollment is ignored so students can't book themselves more time,
+// and the collection's update rule leaves changing it afterwards to staff.
+func applyAccommodations(e *core.RecordEvent) error {
+	test, err := e.App.FindRecordById("tests", e.Record.GetString("test"))
+	if errors.Is(err, sql.ErrNoRows) {
+		// left to the relation field's validation
+		return e.Next()
+	}
+	if err != nil {
+		return err
+	}
+
+	accommodation, err := findAccommodation(e.App, e.Record.GetInt("canvas_student_id"))
+	if err != nil {
+		return err
+	}
+
+	if accommodation == nil {
+		e.Record.Set("duration_mins", test.GetFloat("duration_mins"))
+		return e.Next()
+	}
+
+	e.Record.Set("duration_mins", accommodatedDuration(test.GetFloat("duration_mins"), accommodation))
+
+	requirements := e.Record.GetStringSlice("seat_requirements")
+	for _, requirement := range accommodation.GetStringSlice("seat_requirements") {
+		if !slices.Contains(requirements, requirement) {
+			requirements = append(requirements, requirement)
+		}
+	}
+	e.Record.Set("seat_requirements", requirements)
+
+	return e.Next()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestEnrollmentDurationFromTest(t *testing.T) {
+	app := newTestApp(t)
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "duration_mins": 45})
+	createRecord(t, app, "accommodations", map[string]any{
+		"canvas_student_id": 7,
+		"time_multiplier":   1.5,
+		"break_minutes":     10,
+		"seat_requirements": []string{"accessible"},
+	})
+
+	tests := []struct {
+		name    string
+		student int
+		given   float64
+		want    float64
+	}{
+		{"without a profile", 1, 0, 45},
+		{"without a profile asking for more time", 2, 120, 45},
+		{"without a profile asking for less time", 3, 30, 45},
+		{"with a profile", 7, 0, 78},
+		{"with a profile asking for more time", 7, 120, 78},
+	}
+
+	for _, tt := range tests {
+		enrollment := createRecord(t, app, "test_enrollments", map[string]any{
+			"test":              test.Id,
+			"canvas_student_id": tt.student,
+			"duration_mins":     tt.given,
+		})
+
+		if got := enrollment.GetFloat("duration_mins"); got != tt.want {
+			t.Errorf("%s: got %v minutes, want %v", tt.name, got, tt.want)
+		}
+		if tt.student == 7 && !slices.Contains(enrollment.GetStringSlice("seat_requirements"), "accessible") {
+			t.Errorf("%s: got seat requirements %v, want the profile's", tt.name, enrollment.GetStringSlice("seat_requirements"))
+		}
+	}
+}
+
+func TestEnrollmentDurationUpdateRule(t *testing.T) {
+	app := newTestApp(t)
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "duration_mins": 45})
+	enrollment := createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 1})
+
+	enrollments, err := app.FindCollectionByNameOrId("test_enrollments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staff := createStaff(t, app)
+	tests := []struct {
+		name string
+		info core.RequestInfo
+		want bool
+	}{
+		{"a student moving the start", core.RequestInfo{Body: map[string]any{"start_test_at": time.Now()}}, true},
+		{"a student asking for more time", core.RequestInfo{Body: map[string]any{"duration_mins": 120}}, false},
+		{"a student dropping seat requirements", core.RequestInfo{Body: map[string]any{"seat_requirements": []string{}}}, false},
+		{"staff granting more time", core.RequestInfo{Auth: staff, Body: map[string]any{"duration_mins": 120}}, true},
+	}
+
+	for _, tt := range tests {
+		ok, err := app.CanAccessRecord(enrollment, &tt.info, enrollments.UpdateRule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("%s: got %v from the update rule, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	_ "github.com/richgrov/testing-center/v2/migrations"
+)
+
+// newTestApp returns an app with a fresh, fully migrated database in a
+// temporary directory and the record hooks bound.
+func newTestApp(t *testing.T) core.App {
+	t.Helper()
+
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() })
+
+	if err := app.RunAllMigrations(); err != nil {
+		t.Fatal(err)
+	}
+
+	bindRecordHooks(app)
+	return app
+}
+
+func createRecord(t *testing.T, app core.App, collection string, data map[string]any) *core.Record {
+	t.Helper()
+
+	record, err := newRecord(app, collection, data)
+	if err != nil {
+		t.Fatalf("creating %s: %v", collection, err)
+	}
+
+	return record
+}
+
+func newRecord(app core.App, collection string, data map[string]any) (*core.Record, error) {
+	c, err := app.FindCollectionByNameOrId(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	record := core.NewRecord(c)
+	record.Load(data)
+	if err := app.Save(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// hasValidationCode reports whether err is a validation error with the code
+// on the field.
+func hasValidationCode(err error, field string, code string) bool {
+	var fieldErrors validation.Errors
+	if !errors.As(err, &fieldErrors) {
+		return false
+	}
+
+	var fieldErr validation.Error
+	return errors.As(fieldErrors[field], &fieldErr) && fieldErr.Code() == code
+}
+
+func createStaff(t *testing.T, app core.App) *core.Record {
+	return createRecord(t, app, "users", map[string]any{
+		"email":    "proctor@example.com",
+		"password": "password1234",
+		"role":     "proctor",
+	})
+}
+
+// serve calls the handler as the router would for a request matching the
+// pattern and returns the response. Errors the handler returns are written
+// with their status code.
+func serve(app core.App, auth *core.Record, pattern string, handler func(*core.RequestEvent) error, method string, path string, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		e := &core.RequestEvent{App: app, Event: router.Event{Response: w, Request: r}}
+		e.Auth = auth
+
+		if err := handler(e); err != nil {
+			apiErr := router.ToApiError(err)
+			w.WriteHeader(apiErr.Status)
+			io.WriteString(w, apiErr.Message)
+		}
+	})
+
+	var requestBody io.Reader
+	if body != "" {
+		requestBody = strings.NewReader(body)
+	}
+
+	request := httptest.NewRequest(method, path, requestBody)
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	return recorder
+}
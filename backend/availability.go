@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	defaultSlotGranularity = 15 * time.Minute
+	maxSlotGranularity     = 24 * time.Hour
+)
+
+type bookingSlot struct {
+	Start types.DateTime `json:"start"`
+	End   types.DateTime `json:"end"`
+	// Remaining is how many more students could take the slot.
+	Remaining int `json:"remaining"`
+}
+
+type availability struct {
+	Enrollment      string        `json:"enrollment"`
+	DurationMins    float64       `json:"durationMins"`
+	GranularityMins float64       `json:"granularityMins"`
+	Slots           []bookingSlot `json:"slots"`
+}
+
+// neverCloses stands in for the closing time of a test without one, which
+// checkBooking lets students book at any time after it opens.
+var neverCloses = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// bookableSpan returns when an enrollment's test can be taken, from the later
+// of the test opening, the student's unlock time and now until the test
+// closes, the same limits checkBooking holds bookings to.
+func bookableSpan(test *core.Record, enrollment *core.Record, now time.Time) timeWindow {
+	span := timeWindow{now, neverCloses}
+	if closes := test.GetDateTime("closes"); !closes.IsZero() {
+		span.End = closes.Time()
+	}
+
+	for _, after := range []types.DateTime{test.GetDateTime("opens"), enrollment.GetDateTime("unlock_after")} {
+		if after.Time().After(span.Start) {
+			span.Start = after.Time()
+		}
+	}
+
+	return span
+}
+
+// getBookingSlots returns the times an enrollment could start, every
+// granularity within the testing center hours, that would leave it a seat for
+// its whole duration, by the same rule checkBooking applies. A slot can run
+// from one hours into the ones right after.
+func getBookingSlots(app core.App, enrollment *core.Record, granularity time.Duration, now time.Time) ([]bookingSlot, error) {
+	test, err := app.FindRecordById("tests", enrollment.GetString("test"))
+	if err != nil {
+		return nil, err
+	}
+
+	duration := enrollmentDuration(enrollment)
+	span := bookableSpan(test, enrollment, now)
+	if duration <= 0 || span.End.Sub(span.Start) < duration {
+		return []bookingSlot{}, nil
+	}
+
+	open, err := getOpenHours(app, span)
+	if err != nil {
+		return nil, err
+	}
+
+	booked, err := getBookedWindows(app, span, enrollment.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []bookingSlot{}
+	seen := make(map[time.Time]bool)
+	for _, hours := range open {
+		start := hours.window.Start
+		if span.Start.After(start) {
+			start = span.Start
+		}
+
+		// start times fall on whole multiples of the granularity so slots from
+		// different hours line up
+		if rounded := start.Truncate(granularity); rounded.Before(start) {
+			start = rounded.Add(granularity)
+		}
+
+		for ; start.Before(hours.window.End) && !start.Add(duration).After(span.End); start = start.Add(granularity) {
+			if seen[start] {
+				continue
+			}
+			seen[start] = true
+
+			window := timeWindow{start, start.Add(duration)}
+			remaining, covered := remainingSeats(open, booked, window)
+			if !covered || remaining <= 0 {
+				continue
+			}
+
+			slots = append(slots, bookingSlot{toDateTime(window.Start), toDateTime(window.End), remaining})
+		}
+	}
+
+	slices.SortFunc(slots, func(a, b bookingSlot) int {
+		return a.Start.Time().Compare(b.Start.Time())
+	})
+
+	return slots, nil
+}
+
+// enrollmentAvailability lists the times the enrollment's student can book,
+// with how many seats each has left. The optional granularity query parameter
+// is the minutes between start times.
+func enrollmentAvailability(e *core.RequestEvent) error {
+	enrollment, err := e.App.FindRecordById("test_enrollments", e.Request.PathValue("enrollmentId"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e.NotFoundError("enrollment not found", nil)
+	}
+	if err != nil {
+		return e.InternalServerError("error fetching enrollment", err)
+	}
+
+	granularity := defaultSlotGranularity
+	if param := e.Request.URL.Query().Get("granularity"); param != "" {
+		minutes, err := strconv.Atoi(param)
+		if err != nil || minutes <= 0 || time.Duration(minutes)*time.Minute > maxSlotGranularity {
+			return e.BadRequestError("granularity must be a number of minutes up to a day", nil)
+		}
+		granularity = time.Duration(minutes) * time.Minute
+	}
+
+	slots, err := getBookingSlots(e.App, enrollment, granularity, time.Now())
+	if err != nil {
+		return e.InternalServerError("error fetching availability", err)
+	}
+
+	return e.JSON(http.StatusOK, availability{
+		Enrollment:      enrollment.Id,
+		DurationMins:    enrollment.GetFloat("duration_mins"),
+		GranularityMins: granularity.Minutes(),
+		Slots:           slots,
+	})
+}
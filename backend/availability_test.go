@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBookingSlotsWithoutClosing(t *testing.T) {
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 10, 1)
+
+	f.test.Set("closes", "")
+	if err := f.app.Save(f.test); err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment := createRecord(t, f.app, "test_enrollments", map[string]any{
+		"test":              f.test.Id,
+		"canvas_student_id": 1,
+	})
+
+	slots, err := getBookingSlots(f.app, enrollment, time.Hour, f.at(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 2 {
+		t.Fatalf("got %d slots for a test that never closes, want 2", len(slots))
+	}
+
+	for _, slot := range slots {
+		window := timeWindow{slot.Start.Time(), slot.End.Time()}
+		if reason, err := checkBooking(f.app, enrollment, window); reason != nil || err != nil {
+			t.Errorf("slot at %v offered but rejected: %v, %v", window.Start, reason, err)
+		}
+	}
+}
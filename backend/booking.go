@@ -0,0 +1,301 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var (
+	errBeforeTestOpens   = validation.NewError("validation_before_test_opens", "The test isn't open yet at this time")
+	errAfterTestCloses   = validation.NewError("validation_after_test_closes", "The test closes before this booking ends")
+	errBeforeUnlock      = validation.NewError("validation_before_unlock", "The test isn't unlocked for this student yet at this time")
+	errOutsideHours      = validation.NewError("validation_outside_hours", "The testing center isn't open for the whole booking")
+	errHoursFull         = validation.NewError("validation_hours_full", "The testing center is full during this time")
+	errNoBookingDuration = validation.NewError("validation_no_duration", "The booking must have a duration")
+)
+
+// bookingChanged reports whether a save changes when an enrollment is booked,
+// so saves that only record attendance aren't validated again.
+func bookingChanged(enrollment *core.Record) bool {
+	if enrollment.IsNew() {
+		return true
+	}
+
+	original := enrollment.Original()
+	return !original.GetDateTime("start_test_at").Equal(enrollment.GetDateTime("start_test_at")) ||
+		original.GetFloat("duration_mins") != enrollment.GetFloat("duration_mins")
+}
+
+// bookedWindow returns the time an enrollment other than the one being booked
+// takes up a seat. A student who checked out early frees theirs.
+func bookedWindow(enrollment *core.Record) (timeWindow, bool) {
+	window, ok := enrollmentWindow(enrollment)
+	if checkedOut := enrollment.GetDateTime("checked_out_at"); ok && !checkedOut.IsZero() && checkedOut.Time().Before(window.End) {
+		window.End = checkedOut.Time()
+	}
+
+	return window, ok && window.Start.Before(window.End)
+}
+
+// getBookedWindows returns the windows of the enrollments, other than the
+// excluded one, that take up a seat at some point during the window, along
+// with the slots held for other students. Displaced bookings waiting to be
+// rescheduled don't count.
+func getBookedWindows(app core.App, window timeWindow, excludeEnrollment string) ([]timeWindow, error) {
+	enrollments, err := app.FindRecordsByFilter(
+		"test_enrollments",
+		"id != {:id} && ((checked_in_at = '' && start_test_at < {:end} && start_test_at > {:since}) || (checked_in_at != '' && checked_in_at < {:end} && ends_at > {:start}))",
+		"",
+		0,
+		0,
+		dbx.Params{
+			"id":    excludeEnrollment,
+			"start": toDateTime(window.Start),
+			"end":   toDateTime(window.End),
+			"since": toDateTime(window.Start.Add(-longestExam)),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// slots held for students on the waitlist are taken too
+	holds, err := app.FindRecordsByFilter(
+		"waitlist",
+		"status = 'offered' && enrollment != {:id} && hold_expires_at > {:now} && offered_start < {:end} && offered_end > {:start}",
+		"",
+		0,
+		0,
+		dbx.Params{
+			"id":    excludeEnrollment,
+			"now":   toDateTime(time.Now()),
+			"start": toDateTime(window.Start),
+			"end":   toDateTime(window.End),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	displaced, err := pendingDisplacedEnrollments(app)
+	if err != nil {
+		return nil, err
+	}
+
+	booked := make([]timeWindow, 0, len(enrollments)+len(holds))
+	for _, enrollment := range enrollments {
+		if displaced[enrollment.Id] {
+			continue
+		}
+
+		if other, ok := bookedWindow(enrollment); ok && other.overlaps(window) {
+			booked = append(booked, other)
+		}
+	}
+
+	for _, hold := range holds {
+		booked = append(booked, timeWindow{
+			hold.GetDateTime("offered_start").Time(),
+			hold.GetDateTime("offered_end").Time(),
+		})
+	}
+
+	return booked, nil
+}
+
+// peakBookings returns the most booked windows that overlap at any moment of
+// the window.
+func peakBookings(booked []timeWindow, window timeWindow) int {
+	type edge struct {
+		at    time.Time
+		delta int
+	}
+
+	edges := make([]edge, 0, len(booked)*2)
+	for _, other := range booked {
+		if !other.overlaps(window) {
+			continue
+		}
+
+		// overlaps outside the window don't count
+		start, end := other.Start, other.End
+		if start.Before(window.Start) {
+			start = window.Start
+		}
+		if end.After(window.End) {
+			end = window.End
+		}
+
+		edges = append(edges, edge{start, 1}, edge{end, -1})
+	}
+
+	// a booking ending as another starts frees its seat in time, so ends sort
+	// first
+	slices.SortFunc(edges, func(a, b edge) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return a.delta - b.delta
+	})
+
+	peak, count := 0, 0
+	for _, edge := range edges {
+		count += edge.delta
+		peak = max(peak, count)
+	}
+
+	return peak
+}
+
+// openHours is testing center hours and how many students they can seat at
+// once.
+type openHours struct {
+	window   timeWindow
+	capacity int
+}
+
+// getOpenHours returns the testing center hours that are open at some point
+// during the window.
+func getOpenHours(app core.App, window timeWindow) ([]openHours, error) {
+	records, err := app.FindRecordsByFilter(
+		"testing_center_hours",
+		"opens < {:end} && closes > {:start}",
+		"opens",
+		0,
+		0,
+		dbx.Params{"start": toDateTime(window.Start), "end": toDateTime(window.End)},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	open := make([]openHours, 0, len(records))
+	for _, record := range records {
+		capacity, err := hoursCapacity(app, record)
+		if err != nil {
+			return nil, err
+		}
+
+		open = append(open, openHours{
+			timeWindow{record.GetDateTime("opens").Time(), record.GetDateTime("closes").Time()},
+			capacity,
+		})
+	}
+
+	return open, nil
+}
+
+// remainingSeats returns how many more students could be booked for the
+// whole window: the fewest seats left at any moment of it. The window is
+// split wherever hours open or close, so a booking can run from one hours
+// into the ones right after, and each piece is held to the seats of the hours
+// it falls in, the most seats where hours overlap. covered is false if the
+// hours leave part of the window closed.
+func remainingSeats(open []openHours, booked []timeWindow, window timeWindow) (remaining int, covered bool) {
+	cuts := []time.Time{window.Start, window.End}
+	for _, hours := range open {
+		for _, at := range []time.Time{hours.window.Start, hours.window.End} {
+			if at.After(window.Start) && at.Before(window.End) {
+				cuts = append(cuts, at)
+			}
+		}
+	}
+	slices.SortFunc(cuts, time.Time.Compare)
+	cuts = slices.CompactFunc(cuts, time.Time.Equal)
+
+	remaining = math.MaxInt
+	for i := 1; i < len(cuts); i++ {
+		piece := timeWindow{cuts[i-1], cuts[i]}
+
+		capacity, ok := 0, false
+		for _, hours := range open {
+			if !hours.window.Start.After(piece.Start) && !hours.window.End.Before(piece.End) {
+				capacity = max(capacity, hours.capacity)
+				ok = true
+			}
+		}
+		if !ok {
+			return 0, false
+		}
+
+		remaining = min(remaining, capacity-peakBookings(booked, piece))
+	}
+
+	return remaining, true
+}
+
+// checkBooking returns why the enrollment can't be booked for the window, or
+// nil if it can: it has to fall between when its test opens and closes, start
+// after the student's unlock time, and fit in testing center hours with a
+// seat free at every minute.
+func checkBooking(app core.App, enrollment *core.Record, window timeWindow) (validation.Error, error) {
+	if !window.Start.Before(window.End) {
+		return errNoBookingDuration, nil
+	}
+
+	test, err := app.FindRecordById("tests", enrollment.GetString("test"))
+	if err != nil {
+		return nil, err
+	}
+
+	if opens := test.GetDateTime("opens"); !opens.IsZero() && window.Start.Before(opens.Time()) {
+		return errBeforeTestOpens, nil
+	}
+	if closes := test.GetDateTime("closes"); !closes.IsZero() && window.End.After(closes.Time()) {
+		return errAfterTestCloses, nil
+	}
+	if unlock := enrollment.GetDateTime("unlock_after"); !unlock.IsZero() && window.Start.Before(unlock.Time()) {
+		return errBeforeUnlock, nil
+	}
+
+	open, err := getOpenHours(app, window)
+	if err != nil {
+		return nil, err
+	}
+
+	booked, err := getBookedWindows(app, window, enrollment.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, covered := remainingSeats(open, booked, window)
+	if !covered {
+		return errOutsideHours, nil
+	}
+	if remaining <= 0 {
+		return errHoursFull, nil
+	}
+
+	return nil, nil
+}
+
+// validateBooking refuses to save a test enrollment whose start time or
+// duration changed to a time it can't be booked for. The check and the write
+// share a transaction so concurrent bookings can't both take the last seat.
+func validateBooking(e *core.RecordEvent) error {
+	if e.Record.GetDateTime("start_test_at").IsZero() || !bookingChanged(e.Record) {
+		return e.Next()
+	}
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+
+		start := e.Record.GetDateTime("start_test_at").Time()
+		window := timeWindow{start, start.Add(enrollmentDuration(e.Record))}
+
+		invalid, err := checkBooking(txApp, e.Record, window)
+		if err != nil {
+			return err
+		}
+		if invalid != nil {
+			return validation.Errors{"start_test_at": invalid}
+		}
+
+		return e.Next()
+	})
+}
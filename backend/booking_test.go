@@ -0,0 +1,200 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// bookingFixture is a test in a testing center with hours tomorrow.
+type bookingFixture struct {
+	app  core.App
+	test *core.Record
+	day  time.Time
+}
+
+func newBookingFixture(t *testing.T) *bookingFixture {
+	app := newTestApp(t)
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	test := createRecord(t, app, "tests", map[string]any{
+		"name":          "Midterm",
+		"course_code":   "CSC101",
+		"duration_mins": 60,
+		"opens":         day,
+		"closes":        day.Add(18 * time.Hour),
+	})
+
+	return &bookingFixture{app, test, day}
+}
+
+func (f *bookingFixture) at(hour float64) time.Time {
+	return f.day.Add(time.Duration(hour * float64(time.Hour)))
+}
+
+func (f *bookingFixture) addHours(t *testing.T, opens, closes float64, seats int) {
+	createRecord(t, f.app, "testing_center_hours", map[string]any{
+		"opens":  f.at(opens),
+		"closes": f.at(closes),
+		"seats":  seats,
+	})
+}
+
+// book enrolls the student in the fixture's test starting at the hour.
+func (f *bookingFixture) book(student int, start float64) error {
+	return f.bookTest(f.test, student, start)
+}
+
+func (f *bookingFixture) bookTest(test *core.Record, student int, start float64) error {
+	_, err := newRecord(f.app, "test_enrollments", map[string]any{
+		"test":              test.Id,
+		"canvas_student_id": student,
+		"start_test_at":     f.at(start),
+	})
+	return err
+}
+
+func TestBookingRejections(t *testing.T) {
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 12, 1)
+
+	if err := f.book(1, 9); err != nil {
+		t.Fatalf("booking open hours: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		start float64
+		code  string
+	}{
+		{"before hours", 6, errOutsideHours.Code()},
+		{"past hours closing", 11.5, errOutsideHours.Code()},
+		{"over capacity", 9.5, errHoursFull.Code()},
+		{"before test opens", -1, errBeforeTestOpens.Code()},
+		{"past test closing", 17.5, errAfterTestCloses.Code()},
+	}
+
+	for i, tt := range tests {
+		err := f.book(100+i, tt.start)
+		if !hasValidationCode(err, "start_test_at", tt.code) {
+			t.Errorf("%s: got error %v, want %s", tt.name, err, tt.code)
+		}
+	}
+}
+
+func TestBookingBeforeUnlock(t *testing.T) {
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 12, 1)
+
+	_, err := newRecord(f.app, "test_enrollments", map[string]any{
+		"test":              f.test.Id,
+		"canvas_student_id": 1,
+		"unlock_after":      f.at(10),
+		"start_test_at":     f.at(9),
+	})
+	if !hasValidationCode(err, "start_test_at", errBeforeUnlock.Code()) {
+		t.Fatalf("got error %v, want %s", err, errBeforeUnlock.Code())
+	}
+}
+
+func TestBookingAcrossAdjacentHours(t *testing.T) {
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 12, 1)
+	f.addHours(t, 12, 17, 2)
+
+	if err := f.book(1, 13); err != nil {
+		t.Fatalf("booking afternoon hours: %v", err)
+	}
+
+	long := createRecord(t, f.app, "tests", map[string]any{
+		"name":          "Final",
+		"course_code":   "CSC101",
+		"duration_mins": 150,
+		"opens":         f.day,
+		"closes":        f.day.Add(18 * time.Hour),
+	})
+
+	// the afternoon still has a seat while the first student is there
+	if err := f.bookTest(long, 2, 11); err != nil {
+		t.Fatalf("booking across back-to-back hours: %v", err)
+	}
+
+	// the morning only has one seat, which is now taken until noon
+	if err := f.book(3, 11.5); !hasValidationCode(err, "start_test_at", errHoursFull.Code()) {
+		t.Fatalf("got error %v booking the full morning, want %s", err, errHoursFull.Code())
+	}
+
+	// both afternoon seats are taken from 13:00 to 13:30
+	if err := f.book(4, 12.5); !hasValidationCode(err, "start_test_at", errHoursFull.Code()) {
+		t.Fatalf("got error %v booking the full afternoon, want %s", err, errHoursFull.Code())
+	}
+
+	if err := f.book(5, 14); err != nil {
+		t.Fatalf("booking once the afternoon frees up: %v", err)
+	}
+}
+
+func TestBookingGapBetweenHours(t *testing.T) {
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 12, 1)
+	f.addHours(t, 13, 17, 1)
+
+	if err := f.book(1, 11.5); !hasValidationCode(err, "start_test_at", errOutsideHours.Code()) {
+		t.Fatalf("got error %v booking over lunch, want %s", err, errOutsideHours.Code())
+	}
+}
+
+func TestBookingConcurrentLastSeat(t *testing.T) {
+	const students = 20
+
+	// the race only shows when bookings really run in parallel
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(max(runtime.NumCPU(), 8)))
+
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 12, 1)
+
+	enrollments := make([]*core.Record, students)
+	for i := range enrollments {
+		enrollments[i] = createRecord(t, f.app, "test_enrollments", map[string]any{
+			"test":              f.test.Id,
+			"canvas_student_id": i + 1,
+		})
+	}
+
+	// rescheduling doesn't go through the enrollment count's transaction like
+	// creating does
+	var wg sync.WaitGroup
+	errs := make(chan error, students)
+	for _, enrollment := range enrollments {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			enrollment, err := f.app.FindRecordById("test_enrollments", id)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			enrollment.Set("start_test_at", f.at(9))
+			errs <- f.app.Save(enrollment)
+		}(enrollment.Id)
+	}
+	wg.Wait()
+	close(errs)
+
+	booked := 0
+	for err := range errs {
+		if err == nil {
+			booked++
+		} else if !hasValidationCode(err, "start_test_at", errHoursFull.Code()) {
+			t.Errorf("got error %v, want %s", err, errHoursFull.Code())
+		}
+	}
+
+	if booked != 1 {
+		t.Fatalf("%d students booked the last seat, want 1", booked)
+	}
+}
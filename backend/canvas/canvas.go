@@ -0,0 +1,133 @@
+// Package canvas is a small client for the parts of the Canvas LMS API the
+// testing center uses.
+package canvas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PER_PAGE is how many results are asked for per page. Canvas caps it at 100.
+const PER_PAGE = 100
+
+type Client struct {
+	// BaseURL is the root of the API, e.g. https://lms.example.edu/api/v1/
+	BaseURL string
+	// Token is an access token sent as a bearer token.
+	Token      string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL string, token string) *Client {
+	return &Client{BaseURL: baseURL, Token: token, HTTPClient: http.DefaultClient}
+}
+
+type User struct {
+	Id           int64  `json:"id"`
+	Name         string `json:"name"`
+	SortableName string `json:"sortable_name"`
+}
+
+// nextLink returns the URL of the next page from a Link header, or "" on the
+// last page. A link can have several relation types, e.g. rel="next last",
+// and its URL can contain commas, so the header is read link by link rather
+// than split.
+func nextLink(header string) string {
+	for {
+		start := strings.IndexByte(header, '<')
+		end := strings.IndexByte(header, '>')
+		if start < 0 || end < start {
+			return ""
+		}
+
+		target := header[start+1 : end]
+		header = header[end+1:]
+
+		// the link's parameters run until the next link's URL
+		params := header
+		if next := strings.IndexByte(header, '<'); next >= 0 {
+			params = header[:next]
+		}
+
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+				continue
+			}
+
+			value = strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), ",")), `"`)
+			for _, rel := range strings.Fields(value) {
+				if strings.EqualFold(rel, "next") {
+					return target
+				}
+			}
+		}
+	}
+}
+
+// get fetches one page and returns the URL of the next one.
+func (c *Client) get(ctx context.Context, pageURL string, into any) (next string, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.Token)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return "", fmt.Errorf("canvas: GET %s: %s: %s", request.URL.Path, response.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(into); err != nil {
+		return "", fmt.Errorf("canvas: GET %s: %w", request.URL.Path, err)
+	}
+
+	return nextLink(response.Header.Get("Link")), nil
+}
+
+// getAll fetches every page of a list, following the Link headers.
+func getAll[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := base.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	query.Set("per_page", fmt.Sprint(PER_PAGE))
+	first.RawQuery = query.Encode()
+
+	var all []T
+	for next := first.String(); next != ""; {
+		var page []T
+		next, err = c.get(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, page...)
+	}
+
+	return all, nil
+}
+
+// CourseStudents returns every student enrolled in a course.
+func (c *Client) CourseStudents(ctx context.Context, courseId string) ([]User, error) {
+	return getAll[User](ctx, c, "courses/"+url.PathEscape(courseId)+"/users", url.Values{
+		"enrollment_type[]": {"student"},
+	})
+}
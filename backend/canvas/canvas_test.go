@@ -0,0 +1,99 @@
+package canvas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"none", "", ""},
+		{"last page", `<https://lms.example.edu/api/v1/courses/1/users?page=1>; rel="first"`, ""},
+		{"quoted", `<https://lms.example.edu/a?page=2>; rel="next"`, "https://lms.example.edu/a?page=2"},
+		{"unquoted", `<https://lms.example.edu/a?page=2>; rel=next`, "https://lms.example.edu/a?page=2"},
+		{
+			"among others",
+			`<https://lms.example.edu/a?page=1>; rel="current",<https://lms.example.edu/a?page=2>; rel="next",<https://lms.example.edu/a?page=9>; rel="last"`,
+			"https://lms.example.edu/a?page=2",
+		},
+		{"several rels", `<https://lms.example.edu/a?page=2>; rel="next last"`, "https://lms.example.edu/a?page=2"},
+		{"other params", `<https://lms.example.edu/a?page=2>; title="Next; page"; REL="Next"`, "https://lms.example.edu/a?page=2"},
+		{"comma in url", `<https://lms.example.edu/a?ids=1,2>; rel="current", <https://lms.example.edu/a?ids=1,2&page=2>; rel="next"`, "https://lms.example.edu/a?ids=1,2&page=2"},
+		{"next in another rel", `<https://lms.example.edu/a?page=2>; rel="nextish"`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := nextLink(tt.header); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCourseStudentsPages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/courses/55/users" || r.URL.Query().Get("enrollment_type[]") != "student" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Query().Get("page") == "2" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v1/courses/55/users?page=1>; rel="first"`, server.URL))
+			fmt.Fprint(w, `[{"id":3,"name":"Cy"}]`)
+			return
+		}
+
+		if got := r.URL.Query().Get("per_page"); got != fmt.Sprint(PER_PAGE) {
+			t.Errorf("got per_page %q, want %d", got, PER_PAGE)
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(
+			`<%s/api/v1/courses/55/users?page=1>; rel="current",<%s/api/v1/courses/55/users?page=2&enrollment_type[]=student>; rel="next"`,
+			server.URL,
+			server.URL,
+		))
+		fmt.Fprint(w, `[{"id":1,"name":"Ann"},{"id":2,"name":"Bob"}]`)
+	}))
+	defer server.Close()
+
+	students, err := NewClient(server.URL+"/api/v1/", "token").CourseStudents(context.Background(), "55")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, student := range students {
+		names = append(names, student.Name)
+	}
+	if got := strings.Join(names, ","); got != "Ann,Bob,Cy" {
+		t.Fatalf("got students %s, want Ann,Bob,Cy", got)
+	}
+}
+
+func TestCourseStudentsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"errors":[{"message":"Invalid access token."}]}`)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL+"/api/v1/", "expired").CourseStudents(context.Background(), "55")
+	if err == nil {
+		t.Fatal("got no error from a 401")
+	}
+	if !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "Invalid access token.") {
+		t.Fatalf("got error %q, want the status and Canvas's message", err)
+	}
+}
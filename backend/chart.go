@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"image/png"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/richgrov/testing-center/v2/seating"
+)
+
+// maxCachedCharts bounds how many rendered charts are kept between changes to
+// what they show.
+const maxCachedCharts = 64
+
+type cachedChart struct {
+	data []byte
+	etag string
+	// generation is the cache generation the chart was rendered in.
+	generation uint64
+}
+
+// chartCache holds rendered seating charts until something they were drawn
+// from changes. Every invalidation starts a new generation, so a chart whose
+// render began before one isn't cached afterwards.
+type chartCache struct {
+	mu         sync.Mutex
+	generation uint64
+	charts     map[string]cachedChart
+}
+
+var seatingCharts = &chartCache{charts: make(map[string]cachedChart)}
+
+// get returns the cached chart for the key if there's one from the current
+// generation, and the generation to render a missing chart in.
+func (c *chartCache) get(key string) (chart cachedChart, generation uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chart, ok = c.charts[key]
+	return chart, c.generation, ok && chart.generation == c.generation
+}
+
+// put caches a chart rendered in the generation, unless the cache has been
+// invalidated since. The chart is returned either way.
+func (c *chartCache) put(key string, data []byte, generation uint64) cachedChart {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chart := cachedChart{data, fmt.Sprintf(`"%08x"`, crc32.ChecksumIEEE(data)), generation}
+	if generation != c.generation {
+		return chart
+	}
+
+	if len(c.charts) >= maxCachedCharts {
+		clear(c.charts)
+	}
+
+	c.charts[key] = chart
+	return chart
+}
+
+func (c *chartCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.charts)
+}
+
+// invalidateSeatingCharts drops every cached chart once a change to what they
+// show has been saved.
+func invalidateSeatingCharts(e *core.RecordEvent) error {
+	seatingCharts.invalidate()
+	return e.Next()
+}
+
+// getSeatingChart loads the seats and obstacles of a room, or of every room if
+// room is empty, with the seats assigned during the minute starting at the
+// provided time marked as occupied.
+func getSeatingChart(app core.App, room string, at time.Time) ([]seating.Seat, []seating.Obstacle, error) {
+	window := timeWindow{at, at.Add(time.Minute)}
+
+	seats, err := getAllSeats(app, window)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obstacles, err := getObstacles(app)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seatAssignments, err := getSeatAssignments(app, window)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := markOccupied(app, seats, seatAssignments, ""); err != nil {
+		return nil, nil, err
+	}
+
+	if room == "" {
+		return seats, obstacles, nil
+	}
+
+	roomObstacles := make([]seating.Obstacle, 0, len(obstacles))
+	for _, obstacle := range obstacles {
+		if obstacle.Room == room {
+			roomObstacles = append(roomObstacles, obstacle)
+		}
+	}
+
+	return seatsInRoom(seats, room), roomObstacles, nil
+}
+
+func renderSeatingChart(app core.App, room string, at time.Time, format string) ([]byte, error) {
+	seats, obstacles, err := getSeatingChart(app, room, at)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if format == "svg" {
+		err = seating.RenderSVG(&buf, seats, obstacles)
+	} else {
+		err = png.Encode(&buf, seating.RenderPNG(seats, obstacles))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// seatingChart serves the floor plan with the seats occupied at a time and
+// how visible each seat is. The optional query parameters are room (a room
+// id), at (an RFC 3339 time, defaulting to now) and format (png or svg).
+func seatingChart(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	query := e.Request.URL.Query()
+
+	at := time.Now()
+	if param := query.Get("at"); param != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, param)
+		if err != nil {
+			return e.BadRequestError("invalid time", err)
+		}
+	}
+	at = at.UTC().Truncate(time.Minute)
+
+	format := query.Get("format")
+	contentType := "image/png"
+	switch format {
+	case "", "png":
+		format = "png"
+	case "svg":
+		contentType = "image/svg+xml"
+	default:
+		return e.BadRequestError("format must be png or svg", nil)
+	}
+
+	room := query.Get("room")
+	key := room + "|" + at.Format(time.RFC3339) + "|" + format
+
+	chart, generation, ok := seatingCharts.get(key)
+	if !ok {
+		data, err := renderSeatingChart(e.App, room, at, format)
+		if err != nil {
+			return e.InternalServerError("error rendering seating chart", err)
+		}
+		chart = seatingCharts.put(key, data, generation)
+	}
+
+	e.Response.Header().Set("ETag", chart.etag)
+	e.Response.Header().Set("Cache-Control", "private, no-cache")
+	if e.Request.Header.Get("If-None-Match") == chart.etag {
+		return e.NoContent(http.StatusNotModified)
+	}
+
+	return e.Blob(http.StatusOK, contentType, chart.data)
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestChartCacheStaleRender(t *testing.T) {
+	cache := &chartCache{charts: make(map[string]cachedChart)}
+
+	_, generation, ok := cache.get("room")
+	if ok {
+		t.Fatal("empty cache returned a chart")
+	}
+
+	// the chart changes while the old one is still rendering
+	cache.invalidate()
+	cache.put("room", []byte("stale"), generation)
+
+	if _, _, ok := cache.get("room"); ok {
+		t.Fatal("chart rendered before an invalidation was cached")
+	}
+
+	_, generation, _ = cache.get("room")
+	cache.put("room", []byte("fresh"), generation)
+
+	chart, _, ok := cache.get("room")
+	if !ok || string(chart.data) != "fresh" {
+		t.Fatalf("got chart %q, %v, want the fresh one", chart.data, ok)
+	}
+}
+
+func TestSeatingChartInvalidatedByEnrollments(t *testing.T) {
+	app := newTestApp(t)
+
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101"})
+	seatingCharts.put("room", []byte("chart"), seatingCharts.generation)
+
+	createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 1})
+
+	if _, _, ok := seatingCharts.get("room"); ok {
+		t.Fatal("creating an enrollment didn't invalidate the cached charts")
+	}
+}
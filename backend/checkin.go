@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/list"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/richgrov/testing-center/v2/seating"
+)
+
+var (
+	errAlreadyCheckedIn = errors.New("student is already checked in")
+	errNotCheckedIn     = errors.New("student isn't checked in")
+)
+
+type attendance struct {
+	Enrollment   string         `json:"enrollment"`
+	Student      int64          `json:"student"`
+	Name         string         `json:"name"`
+	Course       string         `json:"course"`
+	Seat         string         `json:"seat"`
+	Room         string         `json:"room"`
+	CheckedInAt  types.DateTime `json:"checkedInAt"`
+	CheckedOutAt types.DateTime `json:"checkedOutAt"`
+	EndsAt       types.DateTime `json:"endsAt"`
+	// Overtime is whether the student is still in the room after their
+	// time ran out.
+	Overtime bool `json:"overtime"`
+}
+
+// checkIn records that the enrollment's student arrived and gives them the
+// full duration of their test from then on. A seat they were already
+// assigned is moved to the new window, or given up if someone else has it by
+// then.
+func checkIn(app core.App, enrollment *core.Record, now time.Time) (timeWindow, error) {
+	if !enrollment.GetDateTime("checked_in_at").IsZero() {
+		return timeWindow{}, errAlreadyCheckedIn
+	}
+
+	window := timeWindow{now, now.Add(enrollmentDuration(enrollment))}
+	enrollment.Set("checked_in_at", toDateTime(window.Start))
+	enrollment.Set("ends_at", toDateTime(window.End))
+	if err := app.Save(enrollment); err != nil {
+		return timeWindow{}, err
+	}
+
+	assignment, err := findSeatAssignment(app, enrollment.Id)
+	if err != nil || assignment == nil {
+		return window, err
+	}
+
+	assignment.Set("starts_at", toDateTime(window.Start))
+	assignment.Set("ends_at", toDateTime(window.End))
+	err = app.Save(assignment)
+	if isSeatConflict(err) {
+		return window, app.Delete(assignment)
+	}
+
+	return window, err
+}
+
+// checkOut records that the enrollment's student left and frees their seat
+// for the rest of its assignment.
+func checkOut(app core.App, enrollment *core.Record, now time.Time) error {
+	if enrollment.GetDateTime("checked_in_at").IsZero() || !enrollment.GetDateTime("checked_out_at").IsZero() {
+		return errNotCheckedIn
+	}
+
+	enrollment.Set("checked_out_at", toDateTime(now))
+	if err := app.Save(enrollment); err != nil {
+		return err
+	}
+
+	assignment, err := findSeatAssignment(app, enrollment.Id)
+	if err != nil || assignment == nil {
+		return err
+	}
+
+	if !assignment.GetDateTime("starts_at").Time().Before(now) {
+		return app.Delete(assignment)
+	}
+
+	assignment.Set("ends_at", toDateTime(now))
+	return app.Save(assignment)
+}
+
+// getAttendance describes the enrollments with the seats they are assigned.
+// Students who haven't checked in are given their planned end time.
+func getAttendance(app core.App, enrollments []*core.Record, now time.Time) ([]attendance, error) {
+	if err := expandRecords(app, enrollments, "test"); err != nil {
+		return nil, err
+	}
+
+	enrollmentIds := make([]string, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		enrollmentIds = append(enrollmentIds, enrollment.Id)
+	}
+
+	seatAssignments, err := app.FindAllRecords(
+		"SeatAssignments",
+		dbx.In("enrollment", list.ToInterfaceSlice(enrollmentIds)...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expandRecords(app, seatAssignments, "seat"); err != nil {
+		return nil, err
+	}
+
+	seats := make(map[string]*core.Record, len(seatAssignments))
+	for _, assignment := range seatAssignments {
+		seats[assignment.GetString("enrollment")] = assignment.ExpandedOne("seat")
+	}
+
+	attendances := make([]attendance, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		attendance := attendance{
+			Enrollment:   enrollment.Id,
+			Student:      int64(enrollment.GetInt("canvas_student_id")),
+			Name:         enrollment.GetString("canvas_student_name"),
+			Course:       seating.CourseOf(enrollment),
+			CheckedInAt:  enrollment.GetDateTime("checked_in_at"),
+			CheckedOutAt: enrollment.GetDateTime("checked_out_at"),
+		}
+
+		if window, ok := enrollmentWindow(enrollment); ok {
+			attendance.EndsAt = toDateTime(window.End)
+			attendance.Overtime = attendance.CheckedOutAt.IsZero() && !now.Before(window.End)
+		}
+
+		if seat := seats[enrollment.Id]; seat != nil {
+			attendance.Seat = seat.GetString("DisplayName")
+			attendance.Room = seat.GetString("room")
+		}
+
+		attendances = append(attendances, attendance)
+	}
+
+	return attendances, nil
+}
+
+func describeAttendance(e *core.RequestEvent, enrollment *core.Record) error {
+	attendances, err := getAttendance(e.App, []*core.Record{enrollment}, time.Now())
+	if err != nil {
+		return e.InternalServerError("error fetching attendance", err)
+	}
+
+	return e.JSON(http.StatusOK, attendances[0])
+}
+
+// checkInStudent checks in the student of an enrollment within its check-in
+// window and assigns them a seat. They stay checked in even if no seat is
+// free so a proctor can seat them by hand.
+func checkInStudent(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	enrollment, window, err := requestEnrollment(e, "enrollmentId")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if !checkInWindow(window).contains(now) {
+		return e.BadRequestError("enrollment isn't within its check-in window", nil)
+	}
+
+	err = e.App.RunInTransaction(func(txApp core.App) error {
+		window, err = checkIn(txApp, enrollment, now)
+		return err
+	})
+	if errors.Is(err, errAlreadyCheckedIn) {
+		return e.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		return e.InternalServerError("error checking in", err)
+	}
+
+	_, err = reserveSeat(e.App, enrollment, window)
+	if err != nil && !errors.Is(err, errNoSeatsAvailable) {
+		return e.InternalServerError("error assigning seat", err)
+	}
+
+	return describeAttendance(e, enrollment)
+}
+
+func checkOutStudent(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	enrollment, _, err := requestEnrollment(e, "enrollmentId")
+	if err != nil {
+		return err
+	}
+
+	err = e.App.RunInTransaction(func(txApp core.App) error {
+		return checkOut(txApp, enrollment, time.Now())
+	})
+	if errors.Is(err, errNotCheckedIn) {
+		return e.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		return e.InternalServerError("error checking out", err)
+	}
+
+	return describeAttendance(e, enrollment)
+}
+
+// inRoom lists the students who are checked in and haven't checked out yet,
+// soonest to finish first. The optional room query parameter limits it to
+// the students seated in one room.
+func inRoom(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	enrollments, err := e.App.FindRecordsByFilter(
+		"test_enrollments",
+		"checked_in_at != '' && checked_out_at = ''",
+		"ends_at",
+		0,
+		0,
+	)
+	if err != nil {
+		return e.InternalServerError("error fetching enrollments", err)
+	}
+
+	attendances, err := getAttendance(e.App, enrollments, time.Now())
+	if err != nil {
+		return e.InternalServerError("error fetching attendance", err)
+	}
+
+	if room := e.Request.URL.Query().Get("room"); room != "" {
+		inRoom := make([]attendance, 0, len(attendances))
+		for _, attendance := range attendances {
+			if attendance.Room == room {
+				inRoom = append(inRoom, attendance)
+			}
+		}
+		attendances = inRoom
+	}
+
+	return e.JSON(http.StatusOK, attendances)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCheckInFieldsUpdateRule(t *testing.T) {
+	app := newTestApp(t)
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "duration_mins": 60})
+	enrollment := createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 1})
+
+	enrollments, err := app.FindCollectionByNameOrId("test_enrollments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name string
+		body map[string]any
+		want bool
+	}{
+		{"moving the start", map[string]any{"start_test_at": now}, true},
+		{"checking in", map[string]any{"checked_in_at": now}, false},
+		{"checking out", map[string]any{"start_test_at": now, "checked_out_at": now}, false},
+		{"staying longer", map[string]any{"ends_at": now.Add(time.Hour)}, false},
+	}
+
+	for _, tt := range tests {
+		ok, err := app.CanAccessRecord(enrollment, &core.RequestInfo{Body: tt.body}, enrollments.UpdateRule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("%s: got %v from the update rule, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
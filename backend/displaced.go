@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/richgrov/testing-center/v2/seating"
+)
+
+// displacedBooking is an upcoming booking that testing center hours no
+// longer have room for.
+type displacedBooking struct {
+	Id            string         `json:"id"`
+	Enrollment    string         `json:"enrollment"`
+	Student       int64          `json:"student"`
+	Name          string         `json:"name"`
+	Course        string         `json:"course"`
+	Reason        string         `json:"reason"`
+	OriginalStart types.DateTime `json:"originalStart"`
+	// SuggestedStart is the valid start time nearest the original one, or
+	// empty if there's none.
+	SuggestedStart types.DateTime `json:"suggestedStart"`
+	// NewStart is where the booking was moved by a re-placement.
+	NewStart types.DateTime `json:"newStart"`
+}
+
+// pendingDisplacedEnrollments returns the ids of the enrollments whose
+// displaced bookings haven't been rescheduled yet. They no longer take up a
+// seat.
+func pendingDisplacedEnrollments(app core.App) (map[string]bool, error) {
+	records, err := app.FindAllRecords("displaced_bookings", dbx.HashExp{"status": "pending"})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]bool, len(records))
+	for _, record := range records {
+		pending[record.GetString("enrollment")] = true
+	}
+
+	return pending, nil
+}
+
+// flagDisplacedBookings records the upcoming bookings during the window that
+// testing center hours no longer fit. When hours are over capacity, the most
+// recently made bookings are displaced first.
+func flagDisplacedBookings(app core.App, window timeWindow, now time.Time) ([]*core.Record, error) {
+	enrollments, err := app.FindRecordsByFilter(
+		"test_enrollments",
+		"checked_in_at = '' && start_test_at > {:now} && start_test_at > {:since} && start_test_at < {:end}",
+		"-updated",
+		0,
+		0,
+		dbx.Params{
+			"now":   toDateTime(now),
+			"since": toDateTime(window.Start.Add(-longestExam)),
+			"end":   toDateTime(window.End),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := pendingDisplacedEnrollments(app)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := app.FindCollectionByNameOrId("displaced_bookings")
+	if err != nil {
+		return nil, err
+	}
+
+	var flagged []*core.Record
+	for _, enrollment := range enrollments {
+		booking, ok := enrollmentWindow(enrollment)
+		if !ok || !booking.overlaps(window) || pending[enrollment.Id] {
+			continue
+		}
+
+		// bookings flagged earlier in the loop already stopped counting
+		invalid, err := checkBooking(app, enrollment, booking)
+		if err != nil {
+			return nil, err
+		}
+
+		if invalid == nil {
+			continue
+		}
+
+		var reason string
+		switch invalid.Code() {
+		case errOutsideHours.Code():
+			reason = "hours_closed"
+		case errHoursFull.Code():
+			reason = "hours_full"
+		default:
+			continue
+		}
+
+		displaced := core.NewRecord(collection)
+		displaced.Set("enrollment", enrollment.Id)
+		displaced.Set("reason", reason)
+		displaced.Set("status", "pending")
+		displaced.Set("original_start", enrollment.GetDateTime("start_test_at"))
+		if err := app.Save(displaced); err != nil {
+			return nil, err
+		}
+
+		flagged = append(flagged, displaced)
+	}
+
+	return flagged, nil
+}
+
+// notifyDisplacedStudent tells a student their booking was displaced. It
+// runs once the displaced booking is saved, after the transaction of the
+// change that displaced it commits, so students aren't told about changes
+// that are rolled back.
+func notifyDisplacedStudent(e *core.RecordEvent) error {
+	notifyStudent(e.App, e.Record.GetString("enrollment"), studentNotification{"displaced", fmt.Sprintf(
+		"The testing center can no longer take your booking at %s. It will be moved to the nearest open time, or you can pick a new one.",
+		e.Record.GetDateTime("original_start").Time().Format(time.RFC1123),
+	)})
+
+	return e.Next()
+}
+
+// recheckChangedHours looks for bookings displaced by testing center hours
+// being shortened or losing seats.
+func recheckChangedHours(e *core.RecordEvent) error {
+	original := e.Record.Original()
+	window := timeWindow{original.GetDateTime("opens").Time(), original.GetDateTime("closes").Time()}
+	seats := original.GetInt("seats")
+
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	shrunk := e.Record.GetDateTime("opens").Time().After(window.Start) ||
+		e.Record.GetDateTime("closes").Time().Before(window.End) ||
+		e.Record.GetInt("seats") < seats
+	if !shrunk {
+		return nil
+	}
+
+	_, err := flagDisplacedBookings(e.App, window, time.Now())
+	return err
+}
+
+// recheckDeletedHours looks for bookings displaced by testing center hours
+// being deleted.
+func recheckDeletedHours(e *core.RecordEvent) error {
+	window := timeWindow{e.Record.GetDateTime("opens").Time(), e.Record.GetDateTime("closes").Time()}
+
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	_, err := flagDisplacedBookings(e.App, window, time.Now())
+	return err
+}
+
+// resolveDisplacement marks an enrollment's displaced booking rescheduled
+// once it's booked again, or dismissed if its booking is cancelled.
+func resolveDisplacement(e *core.RecordEvent) error {
+	changed := bookingChanged(e.Record)
+	if err := e.Next(); err != nil || !changed {
+		return err
+	}
+
+	records, err := e.App.FindAllRecords("displaced_bookings", dbx.HashExp{
+		"enrollment": e.Record.Id,
+		"status":     "pending",
+	})
+	if err != nil {
+		return err
+	}
+
+	start := e.Record.GetDateTime("start_test_at")
+	for _, record := range records {
+		if start.IsZero() {
+			record.Set("status", "dismissed")
+		} else {
+			record.Set("status", "rescheduled")
+			record.Set("new_start", start)
+		}
+
+		if err := e.App.Save(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nearestSlot returns the slot the enrollment could book that starts closest
+// to its original start, the earlier one on a tie.
+func nearestSlot(app core.App, enrollment *core.Record, original time.Time, now time.Time) (slot bookingSlot, ok bool, err error) {
+	slots, err := getBookingSlots(app, enrollment, defaultSlotGranularity, now)
+	if err != nil {
+		return bookingSlot{}, false, err
+	}
+
+	distance := func(s bookingSlot) time.Duration {
+		return max(s.Start.Time().Sub(original), original.Sub(s.Start.Time()))
+	}
+
+	for _, candidate := range slots {
+		if !ok || distance(candidate) < distance(slot) {
+			slot = candidate
+			ok = true
+		}
+	}
+
+	return slot, ok, nil
+}
+
+func describeDisplacedBookings(app core.App, records []*core.Record, now time.Time) ([]displacedBooking, error) {
+	if err := expandRecords(app, records, "enrollment"); err != nil {
+		return nil, err
+	}
+
+	enrollments := make([]*core.Record, 0, len(records))
+	for _, record := range records {
+		if enrollment := record.ExpandedOne("enrollment"); enrollment != nil {
+			enrollments = append(enrollments, enrollment)
+		}
+	}
+
+	if err := expandRecords(app, enrollments, "test"); err != nil {
+		return nil, err
+	}
+
+	described := make([]displacedBooking, 0, len(records))
+	for _, record := range records {
+		enrollment := record.ExpandedOne("enrollment")
+		if enrollment == nil {
+			continue
+		}
+
+		booking := displacedBooking{
+			Id:            record.Id,
+			Enrollment:    enrollment.Id,
+			Student:       int64(enrollment.GetInt("canvas_student_id")),
+			Name:          enrollment.GetString("canvas_student_name"),
+			Course:        seating.CourseOf(enrollment),
+			Reason:        record.GetString("reason"),
+			OriginalStart: record.GetDateTime("original_start"),
+			NewStart:      record.GetDateTime("new_start"),
+		}
+
+		if record.GetString("status") == "pending" {
+			slot, ok, err := nearestSlot(app, enrollment, booking.OriginalStart.Time(), now)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				booking.SuggestedStart = slot.Start
+			}
+		}
+
+		described = append(described, booking)
+	}
+
+	return described, nil
+}
+
+// displacedBookings lists the displaced bookings that haven't been
+// rescheduled yet, soonest first, with the start time each would be moved to.
+func displacedBookings(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	records, err := e.App.FindRecordsByFilter("displaced_bookings", "status = 'pending'", "original_start", 0, 0)
+	if err != nil {
+		return e.InternalServerError("error fetching displaced bookings", err)
+	}
+
+	described, err := describeDisplacedBookings(e.App, records, time.Now())
+	if err != nil {
+		return e.InternalServerError("error finding slots", err)
+	}
+
+	return e.JSON(http.StatusOK, described)
+}
+
+// replaceDisplacedBookings moves displaced bookings to the valid start time
+// nearest their original one and tells their students. The optional ids in
+// the body limit it to some of them; the ones that can't be moved stay
+// pending.
+func replaceDisplacedBookings(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	var body struct {
+		Ids []string `json:"ids"`
+	}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("invalid body", err)
+	}
+
+	records, err := e.App.FindRecordsByFilter("displaced_bookings", "status = 'pending'", "original_start", 0, 0)
+	if err != nil {
+		return e.InternalServerError("error fetching displaced bookings", err)
+	}
+
+	if len(body.Ids) > 0 {
+		records = slices.DeleteFunc(records, func(record *core.Record) bool {
+			return !slices.Contains(body.Ids, record.Id)
+		})
+	}
+
+	if err := expandRecords(e.App, records, "enrollment"); err != nil {
+		return e.InternalServerError("error fetching enrollments", err)
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		enrollment := record.ExpandedOne("enrollment")
+		if enrollment == nil {
+			continue
+		}
+
+		slot, ok, err := nearestSlot(e.App, enrollment, record.GetDateTime("original_start").Time(), now)
+		if err != nil {
+			return e.InternalServerError("error finding slot", err)
+		}
+		if !ok {
+			continue
+		}
+
+		// slots are only checked when saving, so another booking may have
+		// taken this one in the meantime
+		enrollment.Set("start_test_at", slot.Start)
+		var invalid validation.Errors
+		if err := e.App.Save(enrollment); errors.As(err, &invalid) {
+			continue
+		} else if err != nil {
+			return e.InternalServerError("error moving booking", err)
+		}
+
+		notifyStudent(e.App, enrollment.Id, studentNotification{"rescheduled", fmt.Sprintf(
+			"Your booking was moved to %s.",
+			slot.Start.Time().Format(time.RFC1123),
+		)})
+	}
+
+	// reload them to pick up the new starts resolveDisplacement saved
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.Id)
+	}
+
+	records, err = e.App.FindRecordsByIds("displaced_bookings", ids)
+	if err != nil {
+		return e.InternalServerError("error fetching displaced bookings", err)
+	}
+
+	described, err := describeDisplacedBookings(e.App, records, now)
+	if err != nil {
+		return e.InternalServerError("error finding slots", err)
+	}
+
+	return e.JSON(http.StatusOK, described)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/subscriptions"
+)
+
+func TestDisplacedStudentNotifiedAfterCommit(t *testing.T) {
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 12, 1)
+	if err := f.book(1, 9); err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment, err := f.app.FindFirstRecordByFilter("test_enrollments", "canvas_student_id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := subscriptions.NewDefaultClient()
+	client.Subscribe(studentTopic(enrollment.Id))
+	f.app.SubscriptionsBroker().Register(client)
+
+	closeEarly := func(app core.App) error {
+		hours, err := app.FindFirstRecordByFilter("testing_center_hours", "seats = 1")
+		if err != nil {
+			return err
+		}
+
+		hours.Set("closes", f.at(9))
+		return app.Save(hours)
+	}
+
+	errRolledBack := errors.New("rolled back")
+	err = f.app.RunInTransaction(func(txApp core.App) error {
+		if err := closeEarly(txApp); err != nil {
+			return err
+		}
+		return errRolledBack
+	})
+	if !errors.Is(err, errRolledBack) {
+		t.Fatal(err)
+	}
+
+	select {
+	case message := <-client.Channel():
+		t.Fatalf("got notification %s about hours that were never changed", message.Data)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := closeEarly(f.app); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-client.Channel():
+	case <-time.After(time.Second):
+		t.Fatal("got no notification once the hours were changed")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+var errTestFull = validation.NewError("validation_test_full", "The test already has as many enrollments as it allows")
+
+// addEnrollment counts one more enrollment for the test, failing if the test
+// is at its max_enrollments. A max of 0 means there is no limit. The check
+// and the increment are one statement so concurrent enrollments can't both
+// take the last place.
+func addEnrollment(app core.App, testId string) error {
+	result, err := app.NonconcurrentDB().NewQuery(
+		"UPDATE tests SET current_enrollments = current_enrollments + 1 " +
+			"WHERE id = {:test} AND (max_enrollments <= 0 OR current_enrollments < max_enrollments)",
+	).Bind(dbx.Params{"test": testId}).Execute()
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return validation.Errors{"test": errTestFull}
+	}
+
+	return nil
+}
+
+// removeEnrollment counts one enrollment less for the test.
+func removeEnrollment(app core.App, testId string) error {
+	_, err := app.NonconcurrentDB().NewQuery(
+		"UPDATE tests SET current_enrollments = MAX(current_enrollments - 1, 0) WHERE id = {:test}",
+	).Bind(dbx.Params{"test": testId}).Execute()
+	return err
+}
+
+// countNewEnrollment adds a created enrollment to its test's count in the
+// same transaction as the enrollment is saved. The enrollment is validated
+// first: inside a caller's transaction, which isn't rolled back when the save
+// fails, a count taken for an invalid enrollment would stay.
+func countNewEnrollment(e *core.RecordEvent) error {
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+
+		if err := txApp.Validate(e.Record); err != nil {
+			return err
+		}
+
+		if testId := e.Record.GetString("test"); testId != "" {
+			if err := addEnrollment(txApp, testId); err != nil {
+				return err
+			}
+		}
+
+		return e.Next()
+	})
+}
+
+// countMovedEnrollment moves an enrollment's count over when it's changed to
+// another test.
+func countMovedEnrollment(e *core.RecordEvent) error {
+	oldTest := e.Record.Original().GetString("test")
+	newTest := e.Record.GetString("test")
+	if oldTest == newTest {
+		return e.Next()
+	}
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+
+		if oldTest != "" {
+			if err := removeEnrollment(txApp, oldTest); err != nil {
+				return err
+			}
+		}
+
+		if newTest != "" {
+			if err := addEnrollment(txApp, newTest); err != nil {
+				return err
+			}
+		}
+
+		return e.Next()
+	})
+}
+
+// uncountEnrollment removes a deleted enrollment from its test's count.
+func uncountEnrollment(e *core.RecordEvent) error {
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+
+		if testId := e.Record.GetString("test"); testId != "" {
+			if err := removeEnrollment(txApp, testId); err != nil {
+				return err
+			}
+		}
+
+		return e.Next()
+	})
+}
+
+// keepEnrollmentCount stops saves of a test from changing its
+// current_enrollments, which only the enrollment hooks keep. A form or record
+// loaded before students enrolled would otherwise write its old count back.
+func keepEnrollmentCount(e *core.RecordEvent) error {
+	if e.Record.IsNew() {
+		e.Record.Set("current_enrollments", 0)
+		return e.Next()
+	}
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+
+		var count int
+		err := txApp.NonconcurrentDB().
+			Select("current_enrollments").
+			From("tests").
+			Where(dbx.HashExp{"id": e.Record.Id}).
+			Row(&count)
+		if err != nil {
+			return err
+		}
+
+		e.Record.Set("current_enrollments", count)
+		return e.Next()
+	})
+}
+
+type repairedCount struct {
+	test string
+	was  int
+	is   int64
+}
+
+// repairEnrollmentCounts sets every test's current_enrollments to its number
+// of enrollments and returns the counts that were off.
+func repairEnrollmentCounts(app core.App) ([]repairedCount, error) {
+	var repaired []repairedCount
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		tests, err := txApp.FindAllRecords("tests")
+		if err != nil {
+			return err
+		}
+
+		for _, test := range tests {
+			count, err := txApp.CountRecords("test_enrollments", dbx.HashExp{"test": test.Id})
+			if err != nil {
+				return err
+			}
+
+			was := test.GetInt("current_enrollments")
+			if int64(was) == count {
+				continue
+			}
+
+			_, err = txApp.NonconcurrentDB().Update(
+				"tests",
+				dbx.Params{"current_enrollments": count},
+				dbx.HashExp{"id": test.Id},
+			).Execute()
+			if err != nil {
+				return err
+			}
+
+			repaired = append(repaired, repairedCount{fmt.Sprintf("%s (%s)", test.GetString("name"), test.Id), was, count})
+		}
+
+		return nil
+	})
+
+	return repaired, err
+}
+
+func newRepairEnrollmentCountsCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "repair-enrollment-counts",
+		Short: "Recounts the enrollments of every test",
+		RunE: func(command *cobra.Command, args []string) error {
+			repaired, err := repairEnrollmentCounts(app)
+			if err != nil {
+				return err
+			}
+
+			for _, count := range repaired {
+				fmt.Printf("%s: %d -> %d\n", count.test, count.was, count.is)
+			}
+			fmt.Printf("repaired %d tests\n", len(repaired))
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func enrollmentCount(t *testing.T, app core.App, test *core.Record) int {
+	t.Helper()
+
+	record, err := app.FindRecordById("tests", test.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return record.GetInt("current_enrollments")
+}
+
+func TestEnrollmentCountFullTest(t *testing.T) {
+	app := newTestApp(t)
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "max_enrollments": 1})
+
+	createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 1})
+
+	_, err := newRecord(app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 2})
+	if !hasValidationCode(err, "test", errTestFull.Code()) {
+		t.Fatalf("got error %v enrolling in a full test, want %s", err, errTestFull.Code())
+	}
+
+	if got := enrollmentCount(t, app, test); got != 1 {
+		t.Fatalf("got %d enrollments after a refused one, want 1", got)
+	}
+
+	if count, err := app.CountRecords("test_enrollments"); err != nil || count != 1 {
+		t.Fatalf("got %d enrollments saved, %v, want 1", count, err)
+	}
+}
+
+func TestEnrollmentCountConcurrent(t *testing.T) {
+	const students = 50
+	const places = 5
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(max(runtime.NumCPU(), 8)))
+
+	app := newTestApp(t)
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "max_enrollments": places})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, students)
+	for i := range students {
+		wg.Add(1)
+		go func(student int) {
+			defer wg.Done()
+			_, err := newRecord(app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": student})
+			errs <- err
+		}(i + 1)
+	}
+	wg.Wait()
+	close(errs)
+
+	enrolled := 0
+	for err := range errs {
+		if err == nil {
+			enrolled++
+		} else if !hasValidationCode(err, "test", errTestFull.Code()) {
+			t.Errorf("got error %v, want %s", err, errTestFull.Code())
+		}
+	}
+
+	if enrolled != places {
+		t.Fatalf("%d students enrolled in a test with %d places", enrolled, places)
+	}
+	if got := enrollmentCount(t, app, test); got != places {
+		t.Fatalf("got count %d, want %d", got, places)
+	}
+}
+
+func TestEnrollmentCountDelete(t *testing.T) {
+	app := newTestApp(t)
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101"})
+	enrollment := createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 1})
+
+	if err := app.Delete(enrollment); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := enrollmentCount(t, app, test); got != 0 {
+		t.Fatalf("got %d enrollments after deleting the only one, want 0", got)
+	}
+}
+
+func TestEnrollmentCountMove(t *testing.T) {
+	app := newTestApp(t)
+	midterm := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101"})
+	final := createRecord(t, app, "tests", map[string]any{"name": "Final", "course_code": "CSC101", "max_enrollments": 1})
+	created := createRecord(t, app, "test_enrollments", map[string]any{"test": midterm.Id, "canvas_student_id": 1})
+	createRecord(t, app, "test_enrollments", map[string]any{"test": final.Id, "canvas_student_id": 2})
+
+	// a fresh copy so the original test is known
+	enrollment, err := app.FindRecordById("test_enrollments", created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment.Set("test", final.Id)
+	if err := app.Save(enrollment); !hasValidationCode(err, "test", errTestFull.Code()) {
+		t.Fatalf("got error %v moving into a full test, want %s", err, errTestFull.Code())
+	}
+	if got := enrollmentCount(t, app, midterm); got != 1 {
+		t.Fatalf("got %d enrollments on the midterm after a refused move, want 1", got)
+	}
+
+	// final was loaded before anyone enrolled, so this save mustn't write its
+	// count of 0 back
+	final.Set("max_enrollments", 0)
+	if err := app.Save(final); err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment, err = app.FindRecordById("test_enrollments", created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment.Set("test", final.Id)
+	if err := app.Save(enrollment); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := enrollmentCount(t, app, midterm); got != 0 {
+		t.Errorf("got %d enrollments on the midterm after moving away, want 0", got)
+	}
+	if got := enrollmentCount(t, app, final); got != 2 {
+		t.Errorf("got %d enrollments on the final after moving in, want 2", got)
+	}
+}
+
+func TestRepairEnrollmentCounts(t *testing.T) {
+	app := newTestApp(t)
+	midterm := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101"})
+	final := createRecord(t, app, "tests", map[string]any{"name": "Final", "course_code": "CSC101"})
+	createRecord(t, app, "test_enrollments", map[string]any{"test": midterm.Id, "canvas_student_id": 1})
+	createRecord(t, app, "test_enrollments", map[string]any{"test": midterm.Id, "canvas_student_id": 2})
+
+	_, err := app.NonconcurrentDB().Update("tests", dbx.Params{"current_enrollments": 7}, dbx.HashExp{"id": midterm.Id}).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repaired, err := repairEnrollmentCounts(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repaired) != 1 || repaired[0].was != 7 || repaired[0].is != 2 {
+		t.Fatalf("got repairs %+v, want the midterm from 7 to 2", repaired)
+	}
+	if got := enrollmentCount(t, app, midterm); got != 2 {
+		t.Errorf("got %d enrollments on the midterm, want 2", got)
+	}
+	if got := enrollmentCount(t, app, final); got != 0 {
+		t.Errorf("got %d enrollments on the final, want 0", got)
+	}
+
+	command := newRepairEnrollmentCountsCommand(app)
+	command.SetArgs(nil)
+	if err := command.Execute(); err != nil {
+		t.Fatal(err)
+	}
+}
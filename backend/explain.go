@@ -0,0 +1,113 @@
+package main
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/richgrov/testing-center/v2/seating"
+)
+
+const (
+	defaultExplainedAlternatives = 5
+	explainedContributors        = 5
+)
+
+type seatScore struct {
+	Seat  string  `json:"seat"`
+	Score float64 `json:"score"`
+}
+
+type seatContributor struct {
+	Seat       string  `json:"seat"`
+	SameTest   bool    `json:"sameTest"`
+	Visibility float64 `json:"visibility"`
+}
+
+// seatExplanation justifies a seat assignment: how visible the seat is and
+// how well a proctor covers it, the scores of the seats the student could
+// have had instead, and which occupied seats can see it the most. Coverage is
+// null when the room has no proctor station.
+type seatExplanation struct {
+	Seat         string            `json:"seat"`
+	Room         string            `json:"room"`
+	Score        float64           `json:"score"`
+	Visibility   float64           `json:"visibility"`
+	Coverage     *float64          `json:"coverage"`
+	Alternatives []seatScore       `json:"alternatives"`
+	Contributors []seatContributor `json:"contributors"`
+}
+
+// explainSeat scores an enrollment's seat against everyone else seated in its
+// room during the enrollment's window, as the room stands now. At most
+// alternatives other seats and explainedContributors contributors are listed.
+func explainSeat(app core.App, enrollment *core.Record, seat seating.Seat, window timeWindow, alternatives int) (seatExplanation, error) {
+	seats, err := getAllSeats(app, window)
+	if err != nil {
+		return seatExplanation{}, err
+	}
+
+	obstacles, err := getObstacles(app)
+	if err != nil {
+		return seatExplanation{}, err
+	}
+
+	seatAssignments, err := getSeatAssignments(app, window)
+	if err != nil {
+		return seatExplanation{}, err
+	}
+
+	if err := markOccupied(app, seats, seatAssignments, enrollment.Id); err != nil {
+		return seatExplanation{}, err
+	}
+	if err := expandRecords(app, []*core.Record{enrollment}, "test"); err != nil {
+		return seatExplanation{}, err
+	}
+
+	roomSeats := seatsInRoom(seats, seat.Room)
+	student := studentOf(enrollment)
+
+	explanation := seatExplanation{
+		Seat:         seat.Name,
+		Room:         seat.Room,
+		Alternatives: []seatScore{},
+		Contributors: []seatContributor{},
+	}
+
+	for i, other := range roomSeats {
+		if other.Id != seat.Id {
+			continue
+		}
+
+		explanation.Score = seating.SeatScore(i, student.Test, roomSeats, obstacles)
+		explanation.Visibility = seating.SeatVisibility(i, student.Test, roomSeats, obstacles)
+		if coverage, ok := seating.ProctorCoverage(i, roomSeats, obstacles); ok {
+			explanation.Coverage = &coverage
+		}
+
+		for _, contribution := range seating.Contributions(i, student.Test, roomSeats, obstacles) {
+			if len(explanation.Contributors) == explainedContributors {
+				break
+			}
+
+			occupant := roomSeats[contribution.Seat]
+			explanation.Contributors = append(explanation.Contributors, seatContributor{
+				Seat:       occupant.Name,
+				SameTest:   occupant.Test != "" && occupant.Test == student.Test,
+				Visibility: contribution.Visibility,
+			})
+		}
+	}
+
+	for _, candidate := range seating.RankSeats(roomSeats, obstacles, student) {
+		if len(explanation.Alternatives) == alternatives {
+			break
+		}
+
+		if roomSeats[candidate.Seat].Id != seat.Id {
+			explanation.Alternatives = append(explanation.Alternatives, seatScore{
+				Seat:  roomSeats[candidate.Seat].Name,
+				Score: candidate.Score,
+			})
+		}
+	}
+
+	return explanation, nil
+}
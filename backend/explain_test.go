@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSeatAssignmentExplanation(t *testing.T) {
+	app := newTestApp(t)
+	staff := createStaff(t, app)
+
+	now := time.Now()
+	createRecord(t, app, "testing_center_hours", map[string]any{
+		"opens":  now.Add(-time.Hour),
+		"closes": now.Add(3 * time.Hour),
+		"seats":  2,
+	})
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "duration_mins": 60})
+	createRecord(t, app, "seats", map[string]any{"DisplayName": "A1", "X": 0, "Y": 0})
+	createRecord(t, app, "seats", map[string]any{"DisplayName": "A2", "X": 30, "Y": 0})
+
+	assign := func(student int, query string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		enrollment := createRecord(t, app, "test_enrollments", map[string]any{
+			"test":              test.Id,
+			"canvas_student_id": student,
+			"start_test_at":     now.Add(-5 * time.Minute),
+		})
+		response := serve(app, staff, "GET /api/seat-assignment/{enrollmentId}", seatAssignment, "GET", "/api/seat-assignment/"+enrollment.Id+query, "")
+		if response.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", response.Code, response.Body)
+		}
+		return response
+	}
+
+	firstSeat := assign(1, "").Body.String()
+
+	// listing no alternatives still lists who can see the seat
+	var explanation seatExplanation
+	if err := json.Unmarshal(assign(2, "?format=json&alternatives=0").Body.Bytes(), &explanation); err != nil {
+		t.Fatal(err)
+	}
+
+	if explanation.Seat == "" || explanation.Seat == firstSeat {
+		t.Errorf("got seat %q next to %q, want the other one", explanation.Seat, firstSeat)
+	}
+	if len(explanation.Alternatives) != 0 {
+		t.Errorf("got alternatives %+v, want none", explanation.Alternatives)
+	}
+	if len(explanation.Contributors) != 1 || explanation.Contributors[0].Seat != firstSeat || !explanation.Contributors[0].SameTest {
+		t.Errorf("got contributors %+v, want %s taking the same test", explanation.Contributors, firstSeat)
+	}
+}
@@ -1,20 +1,157 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"image/png"
+	"log"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 	"github.com/richgrov/testing-center/v2/seating"
 )
 
+// loadFromDatabase loads the seats and obstacles of a room from the
+// PocketBase data directory, marking the seats assigned at the given time as
+// occupied and leaving out the ones disabled then, as the seating chart does.
+// Without a room, every seat has to be in the same one, since each room has
+// its own coordinate space.
+func loadFromDatabase(dataDir string, roomName string, at time.Time) ([]seating.Seat, []seating.Obstacle, error) {
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: dataDir})
+	if err := app.Bootstrap(); err != nil {
+		return nil, nil, err
+	}
+
+	var roomFilter dbx.Expression
+	if roomName != "" {
+		room, err := app.FindFirstRecordByData("rooms", "name", roomName)
+		if err != nil {
+			return nil, nil, err
+		}
+		roomFilter = dbx.HashExp{"room": room.Id}
+	}
+
+	seatRecords, err := app.FindAllRecords("seats", roomFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if roomName == "" {
+		for _, record := range seatRecords {
+			if record.GetString("room") != seatRecords[0].GetString("room") {
+				return nil, nil, errors.New("the seats are in several rooms, pick one with -room")
+			}
+		}
+	}
+
+	obstacleRecords, err := app.FindAllRecords("obstacles", roomFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now, err := types.ParseDateTime(at)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assignments, err := app.FindRecordsByFilter(
+		"SeatAssignments",
+		"starts_at <= {:at} && ends_at > {:at}",
+		"",
+		0,
+		0,
+		dbx.Params{"at": now},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, err := range app.ExpandRecords(assignments, []string{"enrollment.test"}, nil) {
+		return nil, nil, err
+	}
+
+	occupants := make(map[string]string, len(assignments))
+	for _, assignment := range assignments {
+		occupants[assignment.GetString("seat")] = seating.CourseOf(assignment.ExpandedOne("enrollment"))
+	}
+
+	// -at is given to the second
+	seats := make([]seating.Seat, 0, len(seatRecords))
+	for _, record := range seatRecords {
+		if seating.SeatDisabledDuring(record, at, at.Add(time.Second)) {
+			continue
+		}
+
+		seat := seating.SeatFromRecord(record)
+		seat.Test, seat.Occupied = occupants[record.Id]
+		seats = append(seats, seat)
+	}
+
+	obstacles := make([]seating.Obstacle, 0, len(obstacleRecords))
+	for _, record := range obstacleRecords {
+		obstacles = append(obstacles, seating.ObstacleFromRecord(record))
+	}
+
+	return seats, obstacles, nil
+}
+
 func main() {
-	img := seating.BuildHeatmap()
-	file, err := os.Create("vision.png")
+	seatsPath := flag.String("seats", "seats.csv", "floor plan CSV to draw when not reading the database")
+	obstaclesPath := flag.String("obstacles", "", "optional CSV of walls and dividers in the floor plan")
+	dataDir := flag.String("dir", "", "PocketBase data directory to read seats and assignments from instead of CSV files")
+	roomName := flag.String("room", "", "room to draw when reading the database, required if there is more than one")
+	at := flag.String("at", "", "show the seats occupied at this local time, e.g. \"2025-04-15 09:00:00\" (defaults to now)")
+	out := flag.String("out", "vision.png", "output file; a .svg extension writes an SVG instead of a PNG")
+	flag.Parse()
+
+	var seats []seating.Seat
+	var obstacles []seating.Obstacle
+	var err error
+
+	if *dataDir != "" {
+		when := time.Now()
+		if *at != "" {
+			when, err = time.ParseInLocation(time.DateTime, *at, time.Local)
+			if err != nil {
+				log.Fatalf("invalid -at: %v", err)
+			}
+		}
+
+		seats, obstacles, err = loadFromDatabase(*dataDir, *roomName, when)
+		if err != nil {
+			log.Fatalf("error loading floor plan: %v", err)
+		}
+	} else {
+		seats, err = seating.LoadSeats(*seatsPath)
+		if err != nil {
+			log.Fatalf("error loading seats: %v", err)
+		}
+
+		if *obstaclesPath != "" {
+			obstacles, err = seating.LoadObstacles(*obstaclesPath)
+			if err != nil {
+				log.Fatalf("error loading obstacles: %v", err)
+			}
+		}
+	}
+
+	file, err := os.Create(*out)
 	if err != nil {
 		panic(err)
 	}
+	defer file.Close()
+
+	if filepath.Ext(*out) == ".svg" {
+		err = seating.RenderSVG(file, seats, obstacles)
+	} else {
+		err = png.Encode(file, seating.RenderPNG(seats, obstacles))
+	}
 
-	if err := png.Encode(file, img); err != nil {
+	if err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// hoursHorizon is how far ahead hours rules are expanded. A daily job moves
+// it forward.
+const hoursHorizon = 120 * 24 * time.Hour
+
+const (
+	dateLayout  = "2006-01-02"
+	clockLayout = "15:04"
+)
+
+var (
+	errClosesBeforeOpens = validation.NewError("validation_closes_before_opens", "Must be after the opening time")
+	errUnknownTimezone   = validation.NewError("validation_unknown_timezone", "Unknown time zone")
+)
+
+// ruleLocation returns the time zone the rule's days and times are in, the
+// server's own if it doesn't name one.
+func ruleLocation(rule *core.Record) (*time.Location, error) {
+	if timezone := rule.GetString("timezone"); timezone != "" {
+		return time.LoadLocation(timezone)
+	}
+
+	return time.Local, nil
+}
+
+// atClock returns the time of day on the day, e.g. 08:00.
+func atClock(day time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
+
+// exceptionApplies reports whether the exception changes the rule's hours on
+// the date. Exceptions without a rule apply to every rule.
+func exceptionApplies(exception *core.Record, rule *core.Record, date string) bool {
+	if forRule := exception.GetString("rule"); forRule != "" && forRule != rule.Id {
+		return false
+	}
+
+	from := exception.GetString("date")
+	until := exception.GetString("until")
+	if until == "" {
+		until = from
+	}
+
+	return from <= date && date <= until
+}
+
+// expandHoursRule returns the hours the rule opens the testing center for,
+// keyed by date, from the day containing now until the rule ends or
+// hoursHorizon. Exceptions close days or open them late or close them early.
+func expandHoursRule(rule *core.Record, exceptions []*core.Record, now time.Time) (map[string]timeWindow, error) {
+	loc, err := ruleLocation(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	local := now.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	last := now.Add(hoursHorizon).In(loc)
+
+	if startsOn := rule.GetString("starts_on"); startsOn != "" {
+		first, err := time.ParseInLocation(dateLayout, startsOn, loc)
+		if err != nil {
+			return nil, err
+		}
+		if first.After(day) {
+			day = first
+		}
+	}
+
+	if endsOn := rule.GetString("ends_on"); endsOn != "" {
+		end, err := time.ParseInLocation(dateLayout, endsOn, loc)
+		if err != nil {
+			return nil, err
+		}
+		if end.Before(last) {
+			last = end
+		}
+	}
+
+	days := rule.GetStringSlice("days")
+	windows := make(map[string]timeWindow)
+
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if !slices.Contains(days, strings.ToLower(day.Weekday().String())) {
+			continue
+		}
+
+		date := day.Format(dateLayout)
+		opensAt := rule.GetString("opens_at")
+		closesAt := rule.GetString("closes_at")
+		closed := false
+
+		// times are zero padded, so they compare as strings
+		for _, exception := range exceptions {
+			if !exceptionApplies(exception, rule, date) {
+				continue
+			}
+
+			closed = closed || exception.GetBool("closed")
+			if late := exception.GetString("opens_at"); late > opensAt {
+				opensAt = late
+			}
+			if early := exception.GetString("closes_at"); early != "" && early < closesAt {
+				closesAt = early
+			}
+		}
+		if closed {
+			continue
+		}
+
+		opens, err := atClock(day, opensAt)
+		if err != nil {
+			return nil, err
+		}
+
+		closes, err := atClock(day, closesAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if opens.Before(closes) && closes.After(now) {
+			windows[date] = timeWindow{opens, closes}
+		}
+	}
+
+	return windows, nil
+}
+
+// syncRuleHours makes the rule's upcoming testing center hours match its
+// expansion, keeping the hours of days that are still open so waitlists for
+// them carry over. Edits made by hand to hours a rule created are replaced.
+func syncRuleHours(app core.App, rule *core.Record, now time.Time) error {
+	loc, err := ruleLocation(rule)
+	if err != nil {
+		return err
+	}
+
+	exceptions, err := app.FindRecordsByFilter(
+		"hours_exceptions",
+		"(rule = '' || rule = {:rule}) && (date >= {:today} || until >= {:today})",
+		"",
+		0,
+		0,
+		dbx.Params{"rule": rule.Id, "today": now.In(loc).Format(dateLayout)},
+	)
+	if err != nil {
+		return err
+	}
+
+	windows, err := expandHoursRule(rule, exceptions, now)
+	if err != nil {
+		return err
+	}
+
+	existing, err := app.FindRecordsByFilter(
+		"testing_center_hours",
+		"rule = {:rule} && closes > {:now}",
+		"opens",
+		0,
+		0,
+		dbx.Params{"rule": rule.Id, "now": toDateTime(now)},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, hours := range existing {
+		date := hours.GetDateTime("opens").Time().In(loc).Format(dateLayout)
+		window, ok := windows[date]
+		if !ok {
+			if err := app.Delete(hours); err != nil {
+				return err
+			}
+			continue
+		}
+		delete(windows, date)
+
+		if err := saveRuleHours(app, hours, rule, window); err != nil {
+			return err
+		}
+	}
+
+	collection, err := app.FindCollectionByNameOrId("testing_center_hours")
+	if err != nil {
+		return err
+	}
+
+	dates := make([]string, 0, len(windows))
+	for date := range windows {
+		dates = append(dates, date)
+	}
+	slices.Sort(dates)
+
+	for _, date := range dates {
+		hours := core.NewRecord(collection)
+		hours.Set("rule", rule.Id)
+		if err := saveRuleHours(app, hours, rule, windows[date]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveRuleHours saves testing center hours for a day of the rule if they
+// changed.
+func saveRuleHours(app core.App, hours *core.Record, rule *core.Record, window timeWindow) error {
+	rooms := rule.GetStringSlice("rooms")
+	changed := hours.IsNew() ||
+		!hours.GetDateTime("opens").Time().Equal(window.Start) ||
+		!hours.GetDateTime("closes").Time().Equal(window.End) ||
+		!slices.Equal(hours.GetStringSlice("rooms"), rooms) ||
+		// hours with rooms take their seats from them
+		len(rooms) == 0 && hours.GetInt("seats") != rule.GetInt("seats")
+	if !changed {
+		return nil
+	}
+
+	hours.Set("opens", toDateTime(window.Start))
+	hours.Set("closes", toDateTime(window.End))
+	hours.Set("seats", rule.GetInt("seats"))
+	hours.Set("rooms", rooms)
+	return app.Save(hours)
+}
+
+// syncAllRuleHours expands every hours rule again.
+func syncAllRuleHours(app core.App, now time.Time) error {
+	rules, err := app.FindAllRecords("hours_rules")
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if err := syncRuleHours(app, rule, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkHoursRule refuses hours rules that close before they open or name an
+// unknown time zone.
+func checkHoursRule(e *core.RecordEvent) error {
+	if closesAt := e.Record.GetString("closes_at"); closesAt != "" && closesAt <= e.Record.GetString("opens_at") {
+		return validation.Errors{"closes_at": errClosesBeforeOpens}
+	}
+
+	if _, err := ruleLocation(e.Record); err != nil {
+		return validation.Errors{"timezone": errUnknownTimezone}
+	}
+
+	return e.Next()
+}
+
+// resyncRuleHours expands an hours rule into testing center hours after it's
+// saved.
+func resyncRuleHours(e *core.RecordEvent) error {
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	return syncRuleHours(e.App, e.Record, time.Now())
+}
+
+// deleteRuleHours deletes the testing center hours a rule created that haven't
+// opened yet along with the rule. Hours that already opened stay as a record
+// of when the center was open.
+func deleteRuleHours(e *core.RecordEvent) error {
+	upcoming, err := e.App.FindRecordsByFilter(
+		"testing_center_hours",
+		"rule = {:rule} && opens > {:now}",
+		"",
+		0,
+		0,
+		dbx.Params{"rule": e.Record.Id, "now": toDateTime(time.Now())},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, hours := range upcoming {
+		if err := e.App.Delete(hours); err != nil {
+			return err
+		}
+	}
+
+	return e.Next()
+}
+
+// resyncExceptionHours expands the hours rules again after an exception is
+// added, changed or removed.
+func resyncExceptionHours(e *core.RecordEvent) error {
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	return syncAllRuleHours(e.App, time.Now())
+}
+
+// bindHoursRules expands the hours rules every night so they keep reaching
+// hoursHorizon ahead.
+func bindHoursRules(app core.App) {
+	app.Cron().MustAdd("hoursRules", "0 0 * * *", func() {
+		if err := syncAllRuleHours(app, time.Now()); err != nil {
+			app.Logger().Error("error expanding hours rules", "error", err)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+func TestDeletingRuleKeepsPastHours(t *testing.T) {
+	app := newTestApp(t)
+
+	rule := createRecord(t, app, "hours_rules", map[string]any{
+		"name":      "Every day",
+		"days":      []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"},
+		"opens_at":  "08:00",
+		"closes_at": "17:00",
+		"seats":     5,
+		"timezone":  "UTC",
+	})
+
+	yesterday := time.Now().UTC().Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	past := createRecord(t, app, "testing_center_hours", map[string]any{
+		"opens":  yesterday.Add(8 * time.Hour),
+		"closes": yesterday.Add(17 * time.Hour),
+		"seats":  5,
+		"rule":   rule.Id,
+	})
+
+	upcoming, err := app.FindRecordsByFilter("testing_center_hours", "rule = {:rule} && opens > @now", "", 0, 0, dbx.Params{"rule": rule.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upcoming) == 0 {
+		t.Fatal("rule created no upcoming hours")
+	}
+
+	if err := app.Delete(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.FindRecordById("testing_center_hours", past.Id); err != nil {
+		t.Fatalf("hours that already opened were deleted with their rule: %v", err)
+	}
+
+	left, err := app.CountRecords("testing_center_hours", dbx.NewExp("opens > {:now}", dbx.Params{"now": toDateTime(time.Now())}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Fatalf("got %d upcoming hours after deleting their rule, want 0", left)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// fillIncident defaults an incident to having happened now, at the seat its
+// enrollment is assigned.
+func fillIncident(e *core.RecordEvent) error {
+	if e.Record.GetDateTime("occurred_at").IsZero() {
+		e.Record.Set("occurred_at", toDateTime(time.Now()))
+	}
+
+	if e.Record.GetString("seat") == "" {
+		assignment, err := findSeatAssignment(e.App, e.Record.GetString("enrollment"))
+		if err != nil {
+			return err
+		}
+		if assignment != nil {
+			e.Record.Set("seat", assignment.GetString("seat"))
+		}
+	}
+
+	return e.Next()
+}
+
+// recordIncidentReporter stores which proctor filed an incident.
+func recordIncidentReporter(e *core.RecordRequestEvent) error {
+	if e.Auth != nil && e.Auth.Collection().Name == "users" {
+		e.Record.Set("reported_by", e.Auth.Id)
+	}
+
+	return e.Next()
+}
+
+type seatIncidents struct {
+	Seat       string         `json:"seat"`
+	Name       string         `json:"name"`
+	Room       string         `json:"room"`
+	Count      int            `json:"count"`
+	Categories map[string]int `json:"categories"`
+	Latest     types.DateTime `json:"latest"`
+}
+
+// incidentsBySeat counts the incidents at each seat, most first, so proctors
+// can spot problem locations in a room. The optional query parameters are
+// room (a room id) and since (an RFC 3339 time).
+func incidentsBySeat(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	query := e.Request.URL.Query()
+
+	var since time.Time
+	if param := query.Get("since"); param != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, param)
+		if err != nil {
+			return e.BadRequestError("invalid time", err)
+		}
+	}
+
+	filter := "seat != '' && occurred_at >= {:since}"
+	if query.Get("room") != "" {
+		filter += " && seat.room = {:room}"
+	}
+
+	incidents, err := e.App.FindRecordsByFilter(
+		"incidents",
+		filter,
+		"",
+		0,
+		0,
+		dbx.Params{"since": toDateTime(since), "room": query.Get("room")},
+	)
+	if err != nil {
+		return e.InternalServerError("error fetching incidents", err)
+	}
+
+	if err := expandRecords(e.App, incidents, "seat"); err != nil {
+		return e.InternalServerError("error fetching seats", err)
+	}
+
+	bySeat := make(map[string]*seatIncidents)
+	for _, incident := range incidents {
+		seat := incident.ExpandedOne("seat")
+		if seat == nil {
+			continue
+		}
+
+		summary, ok := bySeat[seat.Id]
+		if !ok {
+			summary = &seatIncidents{
+				Seat:       seat.Id,
+				Name:       seat.GetString("DisplayName"),
+				Room:       seat.GetString("room"),
+				Categories: make(map[string]int),
+			}
+			bySeat[seat.Id] = summary
+		}
+
+		summary.Count++
+		summary.Categories[incident.GetString("category")]++
+		if occurred := incident.GetDateTime("occurred_at"); occurred.After(summary.Latest) {
+			summary.Latest = occurred
+		}
+	}
+
+	summaries := make([]seatIncidents, 0, len(bySeat))
+	for _, summary := range bySeat {
+		summaries = append(summaries, *summary)
+	}
+
+	slices.SortFunc(summaries, func(a, b seatIncidents) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return b.Latest.Time().Compare(a.Latest.Time())
+	})
+
+	return e.JSON(http.StatusOK, summaries)
+}
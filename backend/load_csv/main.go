@@ -1,113 +1,126 @@
 package main
 
 import (
-	"encoding/csv"
-	"fmt"
+	"database/sql"
+	"errors"
 	"log"
-	"math"
-	"os"
-	"strconv"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/richgrov/testing-center/v2/seating"
 )
 
-func directionToAngle(direction string) (float64, error) {
-	switch direction {
-	case "N":
-		return math.Pi / 2, nil
-	case "S":
-		return -math.Pi / 2, nil
-	case "E":
-		return 0, nil
-	case "W":
-		return math.Pi, nil
+// findOrCreateRoom returns the room with the given name, creating it
+// if it doesn't exist yet.
+func findOrCreateRoom(app *pocketbase.PocketBase, name string) (*core.Record, error) {
+	room, err := app.FindFirstRecordByData("rooms", "name", name)
+	if err == nil {
+		return room, nil
 	}
-
-	return 0, fmt.Errorf("invalid direction: %s", direction)
-}
-
-func loadSeats(path string) ([]seating.Seat, error) {
-	file, err := os.Open(path)
-	if err != nil {
+	if !errors.Is(err, sql.ErrNoRows) {
 		return nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
 
-	header, err := reader.Read()
+	roomCollection, err := app.FindCollectionByNameOrId("rooms")
 	if err != nil {
 		return nil, err
 	}
-	_ = header
 
-	records, err := reader.ReadAll()
-	if err != nil {
+	room = core.NewRecord(roomCollection)
+	room.Set("name", name)
+	if err := app.Save(room); err != nil {
 		return nil, err
 	}
 
-	seats := make([]seating.Seat, 0, len(records))
-
-	for _, record := range records {
-		angle, err := directionToAngle(record[1])
-		if err != nil {
-			return nil, err
-		}
-
-		x, err := strconv.ParseFloat(record[2], 64)
-		if err != nil {
-			return nil, err
-		}
-
-		y, err := strconv.ParseFloat(record[3], 64)
-		if err != nil {
-			return nil, err
-		}
-
-		seats = append(seats, seating.Seat{
-			Name:     record[0],
-			X:        x,
-			Y:        y,
-			Angle:    angle,
-			Occupied: false,
-		})
-	}
-
-	return seats, nil
+	return room, nil
 }
 
-func addSeats(seats []seating.Seat, app *pocketbase.PocketBase) error {
+func addFloorPlan(seats []seating.Seat, obstacles []seating.Obstacle, roomName string, app *pocketbase.PocketBase) error {
 	seatCollection, err := app.FindCollectionByNameOrId("seats")
 	if err != nil {
 		return err
 	}
 
+	obstacleCollection, err := app.FindCollectionByNameOrId("obstacles")
+	if err != nil {
+		return err
+	}
+
+	var room *core.Record
+	if roomName != "" {
+		room, err = findOrCreateRoom(app, roomName)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, seat := range seats {
 		record := core.NewRecord(seatCollection)
 		record.Set("DisplayName", seat.Name)
 		record.Set("X", seat.X)
 		record.Set("Y", seat.Y)
 		record.Set("Angle", seat.Angle)
+		record.Set("tags", seat.Tags)
+		record.Set("proctor_station", seat.Proctor)
+		if room != nil {
+			record.Set("room", room.Id)
+		}
 		if err := app.Save(record); err != nil {
 			return err
 		}
 	}
 
-	return nil
-}
+	for _, obstacle := range obstacles {
+		record := core.NewRecord(obstacleCollection)
+		record.Set("X1", obstacle.X1)
+		record.Set("Y1", obstacle.Y1)
+		record.Set("X2", obstacle.X2)
+		record.Set("Y2", obstacle.Y2)
+		if room != nil {
+			record.Set("room", room.Id)
+		}
+		if err := app.Save(record); err != nil {
+			return err
+		}
+	}
 
-func main() {
-	seats, err := loadSeats("seats.csv")
+	if room == nil {
+		return nil
+	}
+
+	capacity, err := app.CountRecords(seatCollection, dbx.HashExp{"room": room.Id, "proctor_station": false})
 	if err != nil {
-		log.Fatalf("error loading seats: %v\n", err)
+		return err
 	}
 
+	room.Set("capacity", capacity)
+	return app.Save(room)
+}
+
+func main() {
 	app := pocketbase.New()
 
+	var path, obstaclesPath, roomName string
+	app.RootCmd.PersistentFlags().StringVar(&path, "file", "seats.csv", "the floor plan CSV to import")
+	app.RootCmd.PersistentFlags().StringVar(&obstaclesPath, "obstacles", "", "optional CSV of walls and dividers in the floor plan")
+	app.RootCmd.PersistentFlags().StringVar(&roomName, "room", "", "the room the floor plan is of, created if it doesn't exist")
+
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
-		if err := addSeats(seats, app); err != nil {
+		seats, err := seating.LoadSeats(path)
+		if err != nil {
+			log.Fatalf("error loading seats: %v\n", err)
+		}
+
+		var obstacles []seating.Obstacle
+		if obstaclesPath != "" {
+			obstacles, err = seating.LoadObstacles(obstaclesPath)
+			if err != nil {
+				log.Fatalf("error loading obstacles: %v\n", err)
+			}
+		}
+
+		if err := addFloorPlan(seats, obstacles, roomName, app); err != nil {
 			log.Fatalf("error inserting seats: %v\b", err)
 		}
 		return se.Next()
@@ -12,8 +12,8 @@ import (
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/plugins/migratecmd"
+	"github.com/richgrov/testing-center/v2/canvas"
 	_ "github.com/richgrov/testing-center/v2/migrations"
-	"github.com/richgrov/testing-center/v2/seating"
 )
 
 func main() {
@@ -27,13 +27,42 @@ func main() {
 		Automigrate: isGoRun,
 	})
 
+	app.RootCmd.AddCommand(newPlanSeatingCommand(app))
+	app.RootCmd.AddCommand(newRepairEnrollmentCountsCommand(app))
+
+	var timerWarnings []int
+	app.RootCmd.PersistentFlags().IntSliceVar(&timerWarnings, "timer-warnings", []int{10, 5}, "minutes left at which proctors are warned about a student's exam timer")
+
+	var canvasURL, canvasToken string
+	app.RootCmd.PersistentFlags().StringVar(&canvasURL, "canvas-url", "https://lms.neumont.edu/api/v1/", "root of the Canvas API that rosters are synced from")
+	app.RootCmd.PersistentFlags().StringVar(&canvasToken, "canvas-token", os.Getenv("CANVAS_TOKEN"), "Canvas access token, defaults to $CANVAS_TOKEN")
+
+	bindRecordHooks(app)
+
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		// serves static files from the provided public dir (if exists)
 		se.Router.GET("/{path...}", apis.Static(os.DirFS("./dist"), false))
 		se.Router.GET("/api/gitea-canvas-adapter", giteaCanvasAdapter)
-		se.Router.GET("/api/seat-assignment/{studentId}", seatAssignment)
+		se.Router.GET("/api/seat-assignment/{enrollmentId}", seatAssignment)
+		se.Router.DELETE("/api/seat-assignment/{enrollmentId}", releaseSeat)
+		se.Router.POST("/api/seat-assignment/{enrollmentId}/move/{seatId}", moveSeat)
+		se.Router.POST("/api/seat-assignment/{enrollmentId}/swap/{otherEnrollmentId}", swapSeats)
+		se.Router.POST("/api/seats/{seatId}/out-of-service", markSeatOutOfService)
+		se.Router.POST("/api/check-in/{enrollmentId}", checkInStudent)
+		se.Router.POST("/api/check-out/{enrollmentId}", checkOutStudent)
+		se.Router.GET("/api/in-room", inRoom)
+		se.Router.GET("/api/availability/{enrollmentId}", enrollmentAvailability)
+		se.Router.GET("/api/incidents/by-seat", incidentsBySeat)
+		se.Router.GET("/api/displaced-bookings", displacedBookings)
+		se.Router.POST("/api/displaced-bookings/replace", replaceDisplacedBookings)
+		se.Router.POST("/api/tests/{testId}/sync-roster", syncTestRoster(canvas.NewClient(canvasURL, canvasToken)))
+		se.Router.GET("/api/seating-chart", seatingChart)
 		se.Router.POST("/api/superUserFetchForward", FetchHandler)
 
+		bindExamTimers(se.App, timerWarnings)
+		bindWaitlist(se.App)
+		bindHoursRules(se.App)
+
 		return se.Next()
 	})
 
@@ -42,6 +71,55 @@ func main() {
 	}
 }
 
+func bindRecordHooks(app core.App) {
+	app.OnRecordCreate("SeatAssignments").BindFunc(checkSeatAvailable)
+	app.OnRecordUpdate("SeatAssignments").BindFunc(checkSeatAvailable)
+
+	// bookings are checked before they're counted, which is the last step
+	// before the save, so a refused booking never takes a place
+	app.OnRecordCreate("test_enrollments").BindFunc(applyAccommodations)
+	app.OnRecordCreate("test_enrollments").BindFunc(validateBooking)
+	app.OnRecordCreate("test_enrollments").BindFunc(countNewEnrollment)
+	app.OnRecordUpdate("test_enrollments").BindFunc(countMovedEnrollment)
+	app.OnRecordDelete("test_enrollments").BindFunc(uncountEnrollment)
+	app.OnRecordCreate("tests").BindFunc(keepEnrollmentCount)
+	app.OnRecordUpdate("tests").BindFunc(keepEnrollmentCount)
+	app.OnRecordUpdate("test_enrollments").BindFunc(validateBooking)
+	app.OnRecordUpdate("test_enrollments").BindFunc(updateWaitlist)
+	app.OnRecordUpdate("test_enrollments").BindFunc(resolveDisplacement)
+	app.OnRecordDelete("test_enrollments").BindFunc(reofferCancelledSlot)
+	app.OnRecordCreate("waitlist").BindFunc(joinWaitlist)
+
+	app.OnRecordCreate("testing_center_hours").BindFunc(syncHoursSeats)
+	app.OnRecordUpdate("testing_center_hours").BindFunc(syncHoursSeats)
+	app.OnRecordUpdate("testing_center_hours").BindFunc(recheckChangedHours)
+	app.OnRecordDelete("testing_center_hours").BindFunc(recheckDeletedHours)
+	app.OnRecordAfterCreateSuccess("displaced_bookings").BindFunc(notifyDisplacedStudent)
+	app.OnRecordUpdate("rooms").BindFunc(resyncRoomHours)
+	app.OnRecordCreate("seats").BindFunc(resyncSeatHours)
+	app.OnRecordUpdate("seats").BindFunc(resyncSeatHours)
+	app.OnRecordDelete("seats").BindFunc(resyncSeatHours)
+
+	app.OnRecordCreate("hours_rules").BindFunc(checkHoursRule)
+	app.OnRecordUpdate("hours_rules").BindFunc(checkHoursRule)
+	app.OnRecordCreate("hours_rules").BindFunc(resyncRuleHours)
+	app.OnRecordUpdate("hours_rules").BindFunc(resyncRuleHours)
+	app.OnRecordDelete("hours_rules").BindFunc(deleteRuleHours)
+	app.OnRecordCreate("hours_exceptions").BindFunc(resyncExceptionHours)
+	app.OnRecordUpdate("hours_exceptions").BindFunc(resyncExceptionHours)
+	app.OnRecordDelete("hours_exceptions").BindFunc(resyncExceptionHours)
+
+	app.OnRecordCreate("incidents").BindFunc(fillIncident)
+	app.OnRecordCreateRequest("incidents").BindFunc(recordIncidentReporter)
+
+	// charts show seats colored by their occupants' tests, laid out by room
+	for _, collection := range []string{"SeatAssignments", "seats", "obstacles", "test_enrollments", "tests", "rooms"} {
+		app.OnRecordAfterCreateSuccess(collection).BindFunc(invalidateSeatingCharts)
+		app.OnRecordAfterUpdateSuccess(collection).BindFunc(invalidateSeatingCharts)
+		app.OnRecordAfterDeleteSuccess(collection).BindFunc(invalidateSeatingCharts)
+	}
+}
+
 type CanvasUser struct {
 	Id    int64  `json:"id"`
 	Name  string `json:"name"`
@@ -106,108 +184,3 @@ func giteaCanvasAdapter(e *core.RequestEvent) error {
 
 	return nil
 }
-
-func getAllSeats(app core.App) ([]seating.Seat, error) {
-	seatCollection, err := app.FindCollectionByNameOrId("seats")
-	if err != nil {
-		return nil, err
-	}
-
-	records, err := app.FindAllRecords(seatCollection)
-	if err != nil {
-		return nil, err
-	}
-
-	seats := make([]seating.Seat, 0, len(records))
-	for _, record := range records {
-		seats = append(seats, seating.Seat{
-			Name:     record.GetString("DisplayName"),
-			X:        record.GetFloat("X"),
-			Y:        record.GetFloat("Y"),
-			Angle:    record.GetFloat("Angle"),
-			Occupied: false,
-		})
-	}
-
-	return seats, nil
-}
-
-func getSeatAssignments(app core.App) (map[string]string, error) {
-	seatAssignmentsCollection, err := app.FindCollectionByNameOrId("SeatAssignments")
-	if err != nil {
-		return nil, err
-	}
-
-	seatAssignments, err := app.FindAllRecords(seatAssignmentsCollection)
-	if err != nil {
-		return nil, err
-	}
-
-	result := make(map[string]string)
-	for _, record := range seatAssignments {
-		studentId := record.GetString("studentId")
-		seatName := record.GetString("seatName")
-		result[studentId] = seatName
-	}
-
-	return result, nil
-}
-
-func assignSeat(app core.App, studentId string, seatName string) error {
-	seatAssignmentsCollection, err := app.FindCollectionByNameOrId("SeatAssignments")
-	if err != nil {
-		return err
-	}
-
-	record := core.NewRecord(seatAssignmentsCollection)
-	record.Set("studentId", studentId)
-	record.Set("seatName", seatName)
-
-	if err = app.Save(record); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func seatAssignment(e *core.RequestEvent) error {
-	studentId := e.Request.PathValue("studentId")
-	if e.Auth == nil {
-		return e.UnauthorizedError("admin privelages required", nil)
-	}
-
-	seats, err := getAllSeats(e.App)
-	if err != nil {
-		return e.InternalServerError("error fetching seats", err)
-	}
-
-	seatAssignments, err := getSeatAssignments(e.App)
-	if err != nil {
-		return e.InternalServerError("error fetching seat assignments", err)
-	}
-
-	for otherStudent, otherSeat := range seatAssignments {
-		if otherStudent == studentId {
-			return e.String(http.StatusOK, otherSeat)
-		}
-
-		for i, seat := range seats {
-			if seat.Name == otherSeat {
-				seats[i].Occupied = true
-			}
-		}
-	}
-
-	seatIdx := seating.LeastVisibleSeat(seats)
-	if seatIdx == -1 {
-		return e.NoContent(204)
-	}
-
-	seatName := seats[seatIdx].Name
-
-	if err := assignSeat(e.App, studentId, seatName); err != nil {
-		return err
-	}
-
-	return e.String(http.StatusOK, seatName)
-}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_129849036")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"indexes": [
+				"CREATE UNIQUE INDEX `+"`"+`idx_SeatAssignments_enrollment`+"`"+` ON `+"`"+`SeatAssignments`+"`"+` (`+"`"+`enrollment`+"`"+`)",
+				"CREATE UNIQUE INDEX `+"`"+`idx_SeatAssignments_seat_starts_at`+"`"+` ON `+"`"+`SeatAssignments`+"`"+` (`+"`"+`seat`+"`"+`, `+"`"+`starts_at`+"`"+`)"
+			]
+		}`), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_129849036")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"indexes": []
+		}`), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,178 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"deleteRule": "@request.auth.collectionName = \"users\" && @request.auth.role = \"admin\"",
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_2378810377",
+					"hidden": false,
+					"id": "relation3688683489",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "enrollment",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"cascadeDelete": false,
+					"collectionId": "pbc_1956964795",
+					"hidden": false,
+					"id": "relation1029453414",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "seat",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "select105650625",
+					"maxSelect": 1,
+					"name": "category",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"suspicious_behavior",
+						"unauthorized_material",
+						"communication",
+						"technical_issue",
+						"disruption",
+						"other"
+					]
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text18589324",
+					"max": 0,
+					"min": 0,
+					"name": "notes",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "date2277522715",
+					"max": "",
+					"min": "",
+					"name": "occurred_at",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"hidden": false,
+					"id": "file347571224",
+					"maxSelect": 1,
+					"maxSize": 10485760,
+					"mimeTypes": [
+						"image/jpeg",
+						"image/png",
+						"image/webp",
+						"image/heic"
+					],
+					"name": "photo",
+					"presentable": false,
+					"protected": true,
+					"required": false,
+					"system": false,
+					"thumbs": null,
+					"type": "file"
+				},
+				{
+					"cascadeDelete": false,
+					"collectionId": "_pb_users_auth_",
+					"hidden": false,
+					"id": "relation340745124",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "reported_by",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_2189087560",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_incidents_seat` + "`" + ` ON ` + "`" + `incidents` + "`" + ` (seat)"
+			],
+			"listRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"name": "incidents",
+			"system": false,
+			"type": "base",
+			"updateRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"viewRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")"
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2189087560")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
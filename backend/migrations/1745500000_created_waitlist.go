@@ -0,0 +1,164 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": "@request.body.enrollment = @request.headers.x_enrollment || (@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\"))",
+			"deleteRule": "enrollment = @request.headers.x_enrollment || (@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\"))",
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_2378810377",
+					"hidden": false,
+					"id": "relation3688683489",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "enrollment",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_539813745",
+					"hidden": false,
+					"id": "relation2317008269",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "hours",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "select2063623452",
+					"maxSelect": 1,
+					"name": "status",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "select",
+					"values": [
+						"waiting",
+						"offered",
+						"accepted",
+						"expired"
+					]
+				},
+				{
+					"exceptDomains": null,
+					"hidden": false,
+					"id": "email3885137012",
+					"name": "email",
+					"onlyDomains": null,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "email"
+				},
+				{
+					"hidden": false,
+					"id": "date4078118532",
+					"max": "",
+					"min": "",
+					"name": "offered_start",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"hidden": false,
+					"id": "date3767503589",
+					"max": "",
+					"min": "",
+					"name": "offered_end",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"hidden": false,
+					"id": "date945899512",
+					"max": "",
+					"min": "",
+					"name": "hold_expires_at",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_3894241073",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_waitlist_enrollment_hours` + "`" + ` ON ` + "`" + `waitlist` + "`" + ` (enrollment, hours)",
+				"CREATE INDEX ` + "`" + `idx_waitlist_status` + "`" + ` ON ` + "`" + `waitlist` + "`" + ` (status)"
+			],
+			"listRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"name": "waitlist",
+			"system": false,
+			"type": "base",
+			"updateRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"viewRule": "enrollment = @request.headers.x_enrollment || (@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\"))"
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3894241073")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
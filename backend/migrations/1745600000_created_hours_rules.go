@@ -0,0 +1,203 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"deleteRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1579384326",
+					"max": 0,
+					"min": 0,
+					"name": "name",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "select3957652582",
+					"maxSelect": 7,
+					"name": "days",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"sunday",
+						"monday",
+						"tuesday",
+						"wednesday",
+						"thursday",
+						"friday",
+						"saturday"
+					]
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3411960748",
+					"max": 0,
+					"min": 0,
+					"name": "opens_at",
+					"pattern": "^([01]\\d|2[0-3]):[0-5]\\d$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2430414425",
+					"max": 0,
+					"min": 0,
+					"name": "closes_at",
+					"pattern": "^([01]\\d|2[0-3]):[0-5]\\d$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "number3219281744",
+					"max": null,
+					"min": 0,
+					"name": "seats",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"cascadeDelete": false,
+					"collectionId": "pbc_3085411453",
+					"hidden": false,
+					"id": "relation2090932886",
+					"maxSelect": 999,
+					"minSelect": 0,
+					"name": "rooms",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text922858135",
+					"max": 0,
+					"min": 0,
+					"name": "timezone",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text910263432",
+					"max": 0,
+					"min": 0,
+					"name": "starts_on",
+					"pattern": "^\\d{4}-\\d{2}-\\d{2}$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1286299219",
+					"max": 0,
+					"min": 0,
+					"name": "ends_on",
+					"pattern": "^\\d{4}-\\d{2}-\\d{2}$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_1781314778",
+			"indexes": [],
+			"listRule": "",
+			"name": "hours_rules",
+			"system": false,
+			"type": "base",
+			"updateRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"viewRule": ""
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_1781314778")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
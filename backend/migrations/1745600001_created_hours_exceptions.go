@@ -0,0 +1,169 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"deleteRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2862495610",
+					"max": 0,
+					"min": 0,
+					"name": "date",
+					"pattern": "^\\d{4}-\\d{2}-\\d{2}$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2401904003",
+					"max": 0,
+					"min": 0,
+					"name": "until",
+					"pattern": "^\\d{4}-\\d{2}-\\d{2}$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "bool80170468",
+					"name": "closed",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "bool"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3411960748",
+					"max": 0,
+					"min": 0,
+					"name": "opens_at",
+					"pattern": "^([01]\\d|2[0-3]):[0-5]\\d$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2430414425",
+					"max": 0,
+					"min": 0,
+					"name": "closes_at",
+					"pattern": "^([01]\\d|2[0-3]):[0-5]\\d$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_1781314778",
+					"hidden": false,
+					"id": "relation1188605132",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "rule",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3485334036",
+					"max": 0,
+					"min": 0,
+					"name": "note",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_1765702769",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_hours_exceptions_date` + "`" + ` ON ` + "`" + `hours_exceptions` + "`" + ` (date)"
+			],
+			"listRule": "",
+			"name": "hours_exceptions",
+			"system": false,
+			"type": "base",
+			"updateRule": "@request.auth.collectionName = \"users\" && (@request.auth.role = \"proctor\" || @request.auth.role = \"admin\")",
+			"viewRule": ""
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_1765702769")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
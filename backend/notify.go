@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/routine"
+	"github.com/pocketbase/pocketbase/tools/subscriptions"
+)
+
+// studentNotification is published to a student's booking page when
+// something changes their booking.
+type studentNotification struct {
+	// Type is "waitlist_offer", "displaced" or "rescheduled".
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// studentTopic is the realtime topic the booking page of an enrollment
+// subscribes to. Like the page itself, knowing the enrollment id is enough.
+func studentTopic(enrollmentId string) string {
+	return "enrollments/" + enrollmentId + "/notifications"
+}
+
+// notifyStudent publishes the notification to everyone watching the
+// enrollment's booking page. It's best effort: a student who isn't watching
+// sees the change the next time they open the page.
+func notifyStudent(app core.App, enrollmentId string, notification studentNotification) {
+	// a struct of strings always marshals
+	data, _ := json.Marshal(notification)
+
+	topic := studentTopic(enrollmentId)
+	message := subscriptions.Message{Name: topic, Data: data}
+
+	for _, client := range app.SubscriptionsBroker().Clients() {
+		if client.HasSubscription(topic) {
+			routine.FireAndForget(func() {
+				client.Send(message)
+			})
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/richgrov/testing-center/v2/seating"
+	"github.com/spf13/cobra"
+)
+
+// findSessionEnrollments returns the scheduled enrollments whose test window
+// overlaps the session, with "test" expanded.
+func findSessionEnrollments(app core.App, session timeWindow) ([]*core.Record, error) {
+	// an enrollment can start before the session and still overlap it, so
+	// look back a day and filter precisely below
+	candidates, err := app.FindRecordsByFilter(
+		"test_enrollments",
+		"start_test_at != '' && start_test_at < {:end} && start_test_at > {:earliest}",
+		"start_test_at",
+		0,
+		0,
+		dbx.Params{
+			"earliest": toDateTime(session.Start.Add(-24 * time.Hour)),
+			"end":      toDateTime(session.End),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollments := make([]*core.Record, 0, len(candidates))
+	for _, enrollment := range candidates {
+		window, ok := enrollmentWindow(enrollment)
+		if ok && window.overlaps(session) {
+			enrollments = append(enrollments, enrollment)
+		}
+	}
+
+	if err := expandRecords(app, enrollments, "test"); err != nil {
+		return nil, err
+	}
+
+	return enrollments, nil
+}
+
+type plannedSeat struct {
+	seat       seating.Seat
+	enrollment *core.Record
+}
+
+// overlappingGroups splits enrollments sorted by start into runs whose test
+// windows overlap, one after another. Students of different groups are never
+// in the room at once, so each group can reuse the seats of the others. It
+// also returns the span of each group.
+func overlappingGroups(enrollments []*core.Record) ([][]*core.Record, []timeWindow) {
+	var groups [][]*core.Record
+	var spans []timeWindow
+
+	for _, enrollment := range enrollments {
+		window, _ := enrollmentWindow(enrollment)
+
+		last := len(groups) - 1
+		if last >= 0 && window.Start.Before(spans[last].End) {
+			groups[last] = append(groups[last], enrollment)
+			if window.End.After(spans[last].End) {
+				spans[last].End = window.End
+			}
+			continue
+		}
+
+		groups = append(groups, []*core.Record{enrollment})
+		spans = append(spans, window)
+	}
+
+	return groups, spans
+}
+
+// planSeating seats the unseated enrollments of the session using
+// seating.OptimizeSeating, all at once within each group of overlapping test
+// windows. Students that already have a seat stay in it.
+func planSeating(app core.App, session timeWindow, options seating.OptimizeOptions) ([]plannedSeat, error) {
+	obstacles, err := getObstacles(app)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollments, err := findSessionEnrollments(app, session)
+	if err != nil {
+		return nil, err
+	}
+
+	seatAssignments, err := getSeatAssignments(app, session)
+	if err != nil {
+		return nil, err
+	}
+
+	seated := make(map[string]bool, len(seatAssignments))
+	for _, assignment := range seatAssignments {
+		seated[assignment.GetString("enrollment")] = true
+	}
+
+	unseated := make([]*core.Record, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		if !seated[enrollment.Id] {
+			unseated = append(unseated, enrollment)
+		}
+	}
+
+	plan := make([]plannedSeat, 0, len(unseated))
+	groups, spans := overlappingGroups(unseated)
+	for i, group := range groups {
+		seats, err := getAllSeats(app, spans[i])
+		if err != nil {
+			return nil, err
+		}
+
+		groupAssignments, err := getSeatAssignments(app, spans[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if err := markOccupied(app, seats, groupAssignments, ""); err != nil {
+			return nil, err
+		}
+
+		roster := make([]seating.Student, len(group))
+		for j, enrollment := range group {
+			roster[j] = studentOf(enrollment)
+		}
+
+		assignment, err := seating.OptimizeSeating(seats, obstacles, roster, options)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, enrollment := range group {
+			plan = append(plan, plannedSeat{seats[assignment[j]], enrollment})
+		}
+	}
+
+	return plan, nil
+}
+
+func printSeatingChart(plan []plannedSeat) error {
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].seat.Name < plan[j].seat.Name
+	})
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "SEAT\tSTUDENT\tCOURSE\tSTARTS")
+	for _, planned := range plan {
+		window, _ := enrollmentWindow(planned.enrollment)
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\n",
+			planned.seat.Name,
+			planned.enrollment.GetString("canvas_student_name"),
+			seating.CourseOf(planned.enrollment),
+			window.Start.Local().Format(time.Kitchen),
+		)
+	}
+
+	return writer.Flush()
+}
+
+func newPlanSeatingCommand(app core.App) *cobra.Command {
+	var from, to string
+	var objective string
+	var seed int64
+	var save bool
+
+	command := &cobra.Command{
+		Use:   "plan-seating",
+		Short: "Seats every student of a session at once and prints the seating chart",
+		RunE: func(command *cobra.Command, args []string) error {
+			start, err := time.ParseInLocation(time.DateTime, from, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid --from: %w", err)
+			}
+
+			end, err := time.ParseInLocation(time.DateTime, to, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid --to: %w", err)
+			}
+
+			options := seating.OptimizeOptions{Seed: seed}
+			switch objective {
+			case "total":
+				options.Objective = seating.TotalVisibility
+			case "worst":
+				options.Objective = seating.WorstVisibility
+			default:
+				return fmt.Errorf("invalid --objective: %s", objective)
+			}
+
+			session := timeWindow{start, end}
+			plan, err := planSeating(app, session, options)
+			if err != nil {
+				return err
+			}
+
+			if save {
+				err := app.RunInTransaction(func(txApp core.App) error {
+					for _, planned := range plan {
+						window, _ := enrollmentWindow(planned.enrollment)
+						if err := assignSeat(txApp, planned.enrollment.Id, planned.seat.Id, window); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			return printSeatingChart(plan)
+		},
+	}
+
+	command.Flags().StringVar(&from, "from", "", "session start, e.g. \"2025-04-15 08:00:00\" (local time)")
+	command.Flags().StringVar(&to, "to", "", "session end (local time)")
+	command.Flags().StringVar(&objective, "objective", "total", "minimize \"total\" or \"worst\" pairwise visibility")
+	command.Flags().Int64Var(&seed, "seed", 1, "random seed; the same seed always gives the same chart")
+	command.Flags().BoolVar(&save, "save", false, "store the planned seats as seat assignments")
+	command.MarkFlagRequired("from")
+	command.MarkFlagRequired("to")
+
+	return command
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/richgrov/testing-center/v2/seating"
+)
+
+func TestPlanSeatingReusesSeats(t *testing.T) {
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 12, 2)
+	seat := createRecord(t, f.app, "seats", map[string]any{"DisplayName": "A1"})
+
+	// back to back, so both can have the room's only seat
+	for student, start := range []float64{9, 10} {
+		if err := f.book(student+1, start); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	session := timeWindow{f.at(8), f.at(12)}
+	plan, err := planSeating(f.app, session, seating.OptimizeOptions{Seed: 1})
+	if err != nil {
+		t.Fatalf("planning a seat used twice in a row: %v", err)
+	}
+	if len(plan) != 2 || plan[0].seat.Id != seat.Id || plan[1].seat.Id != seat.Id {
+		t.Fatalf("got plan %+v, want both students in A1", plan)
+	}
+
+	// a third student there at the same time as the second has nowhere to sit
+	if err := f.book(3, 10.5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := planSeating(f.app, session, seating.OptimizeOptions{Seed: 1}); !errors.Is(err, seating.ErrNotEnoughSeats) {
+		t.Fatalf("got error %v with two students at once in one seat, want ErrNotEnoughSeats", err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// isStaffRecord reports whether an auth record is a superuser or a user with
+// the proctor or admin role.
+func isStaffRecord(auth *core.Record) bool {
+	if auth == nil {
+		return false
+	}
+	if auth.IsSuperuser() {
+		return true
+	}
+
+	role := auth.GetString("role")
+	return auth.Collection().Name == "users" && (role == "proctor" || role == "admin")
+}
+
+// requireStaff refuses requests that aren't made by a proctor or admin.
+func requireStaff(e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("admin privelages required", nil)
+	}
+	if !isStaffRecord(e.Auth) {
+		return e.ForbiddenError("proctor or admin role required", nil)
+	}
+
+	return nil
+}
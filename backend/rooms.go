@@ -0,0 +1,261 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/list"
+	"github.com/richgrov/testing-center/v2/seating"
+)
+
+type roomLoad struct {
+	capacity int
+	occupied int
+	// suitable is whether the room has a free seat the student can sit in.
+	suitable bool
+}
+
+func (r *roomLoad) available() bool {
+	return r.suitable && r.occupied < r.capacity
+}
+
+func (r *roomLoad) ratio() float64 {
+	return float64(r.occupied) / float64(r.capacity)
+}
+
+// getRoomLoads returns how full each room is, keyed by room id. Seats that
+// aren't in a room are counted under the empty id.
+func getRoomLoads(app core.App, seats []seating.Seat, student seating.Student) (map[string]*roomLoad, error) {
+	loads := make(map[string]*roomLoad)
+	for _, seat := range seats {
+		if seat.Proctor {
+			continue
+		}
+
+		load, ok := loads[seat.Room]
+		if !ok {
+			load = &roomLoad{}
+			loads[seat.Room] = load
+		}
+
+		load.capacity++
+		if seat.Occupied {
+			load.occupied++
+		} else if seat.Satisfies(&student) {
+			load.suitable = true
+		}
+	}
+
+	rooms, err := app.FindAllRecords("rooms")
+	if err != nil {
+		return nil, err
+	}
+
+	// a room's capacity can cap how many of its seats are used, but disabled
+	// seats aren't in the list so it can't add any
+	for _, room := range rooms {
+		if load, ok := loads[room.Id]; ok && room.GetInt("capacity") > 0 {
+			load.capacity = min(load.capacity, room.GetInt("capacity"))
+		}
+	}
+
+	return loads, nil
+}
+
+// chooseRoom picks the room a student is seated in: the test's preferred room
+// if it can take them, otherwise the least loaded room that can. ok is false
+// if no room can.
+func chooseRoom(app core.App, seats []seating.Seat, student seating.Student, preferredRoom string) (room string, ok bool, err error) {
+	loads, err := getRoomLoads(app, seats, student)
+	if err != nil {
+		return "", false, err
+	}
+
+	if load, found := loads[preferredRoom]; found && preferredRoom != "" && load.available() {
+		return preferredRoom, true, nil
+	}
+
+	for id, load := range loads {
+		if !load.available() {
+			continue
+		}
+
+		if !ok || load.ratio() < loads[room].ratio() || load.ratio() == loads[room].ratio() && id < room {
+			room = id
+			ok = true
+		}
+	}
+
+	return room, ok, nil
+}
+
+func seatsInRoom(seats []seating.Seat, room string) []seating.Seat {
+	inRoom := make([]seating.Seat, 0, len(seats))
+	for _, seat := range seats {
+		if seat.Room == room {
+			inRoom = append(inRoom, seat)
+		}
+	}
+
+	return inRoom
+}
+
+// syncHoursSeats sets the seat count of testing center hours that list rooms
+// to the combined capacity of those rooms, less the seats in them that are
+// disabled at some point during the hours.
+func syncHoursSeats(e *core.RecordEvent) error {
+	roomIds := e.Record.GetStringSlice("rooms")
+	if len(roomIds) > 0 {
+		rooms, err := e.App.FindRecordsByIds("rooms", roomIds)
+		if err != nil {
+			return err
+		}
+
+		seats := 0
+		for _, room := range rooms {
+			seats += room.GetInt("capacity")
+		}
+
+		disabled, err := countDisabledSeats(e.App, roomIds, timeWindow{
+			e.Record.GetDateTime("opens").Time(),
+			e.Record.GetDateTime("closes").Time(),
+		})
+		if err != nil {
+			return err
+		}
+
+		e.Record.Set("seats", max(seats-disabled, 0))
+	}
+
+	return e.Next()
+}
+
+// countDisabledSeats counts the seats in the rooms that are disabled at some
+// point during the window, or the ones in any room if no rooms are given.
+func countDisabledSeats(app core.App, roomIds []string, window timeWindow) (int, error) {
+	filter := dbx.Expression(dbx.HashExp{"status": "disabled", "proctor_station": false})
+	if len(roomIds) > 0 {
+		filter = dbx.And(dbx.In("room", list.ToInterfaceSlice(roomIds)...), filter)
+	}
+
+	seats, err := app.FindAllRecords("seats", filter)
+	if err != nil {
+		return 0, err
+	}
+
+	disabled := 0
+	for _, seat := range seats {
+		if seatDisabledDuring(seat, window) {
+			disabled++
+		}
+	}
+
+	return disabled, nil
+}
+
+// hoursCapacity returns how many students testing center hours can seat at
+// once. Hours that list rooms already leave out their disabled seats, see
+// syncHoursSeats. The seat count of hours that don't is set by hand, so the
+// seats disabled during them in any room are taken off it here.
+func hoursCapacity(app core.App, hours *core.Record) (int, error) {
+	seats := hours.GetInt("seats")
+	if len(hours.GetStringSlice("rooms")) > 0 {
+		return seats, nil
+	}
+
+	disabled, err := countDisabledSeats(app, nil, timeWindow{
+		hours.GetDateTime("opens").Time(),
+		hours.GetDateTime("closes").Time(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return max(seats-disabled, 0), nil
+}
+
+// resaveUpcomingHours saves the testing center hours that include the room
+// and haven't closed yet again so their seat count is recalculated.
+func resaveUpcomingHours(app core.App, roomId string) error {
+	hours, err := app.FindRecordsByFilter(
+		"testing_center_hours",
+		"rooms.id ?= {:room} && closes > {:now}",
+		"",
+		0,
+		0,
+		dbx.Params{"room": roomId, "now": toDateTime(time.Now())},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range hours {
+		if err := app.Save(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resyncRoomHours updates the seat count of upcoming testing center hours
+// after a room's capacity changes.
+func resyncRoomHours(e *core.RecordEvent) error {
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	return resaveUpcomingHours(e.App, e.Record.Id)
+}
+
+// recheckRoomlessHours looks for bookings displaced from upcoming testing
+// center hours that don't list rooms by a seat being disabled. Hours that list
+// rooms are rechecked when resaving them lowers their seat count.
+func recheckRoomlessHours(app core.App, seat *core.Record) error {
+	if seat.GetString("status") != "disabled" {
+		return nil
+	}
+
+	now := time.Now()
+	hours, err := app.FindRecordsByFilter(
+		"testing_center_hours",
+		"rooms:length = 0 && closes > {:now}",
+		"opens",
+		0,
+		0,
+		dbx.Params{"now": toDateTime(now)},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range hours {
+		window := timeWindow{record.GetDateTime("opens").Time(), record.GetDateTime("closes").Time()}
+		if !seatDisabledDuring(seat, window) {
+			continue
+		}
+
+		if _, err := flagDisplacedBookings(app, window, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resyncSeatHours updates the capacity of upcoming testing center hours after
+// a seat is added, removed, enabled or disabled.
+func resyncSeatHours(e *core.RecordEvent) error {
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	if room := e.Record.GetString("room"); room != "" {
+		if err := resaveUpcomingHours(e.App, room); err != nil {
+			return err
+		}
+	}
+
+	return recheckRoomlessHours(e.App, e.Record)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDisabledSeatsReduceHoursWithoutRooms(t *testing.T) {
+	app := newTestApp(t)
+
+	opens := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	hours := createRecord(t, app, "testing_center_hours", map[string]any{
+		"opens":  opens,
+		"closes": opens.Add(4 * time.Hour),
+		"seats":  2,
+	})
+	room := createRecord(t, app, "rooms", map[string]any{"name": "Lab", "capacity": 2})
+	createRecord(t, app, "seats", map[string]any{"DisplayName": "A1", "room": room.Id})
+	seat := createRecord(t, app, "seats", map[string]any{"DisplayName": "A2", "room": room.Id})
+
+	seat.Set("status", "disabled")
+	if err := app.Save(seat); err != nil {
+		t.Fatal(err)
+	}
+
+	capacity, err := hoursCapacity(app, hours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity != 1 {
+		t.Fatalf("got capacity %d with a seat disabled, want 1", capacity)
+	}
+
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "duration_mins": 60})
+	createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 1, "start_test_at": opens})
+
+	_, err = newRecord(app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 2, "start_test_at": opens})
+	if !hasValidationCode(err, "start_test_at", errHoursFull.Code()) {
+		t.Fatalf("got error %v booking past the reduced capacity, want %s", err, errHoursFull.Code())
+	}
+}
+
+func TestSeatChangesResyncRoomHours(t *testing.T) {
+	app := newTestApp(t)
+
+	room := createRecord(t, app, "rooms", map[string]any{"name": "Lab", "capacity": 3})
+	opens := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	hours := createRecord(t, app, "testing_center_hours", map[string]any{
+		"opens":  opens,
+		"closes": opens.Add(4 * time.Hour),
+		"rooms":  []string{room.Id},
+	})
+
+	seats := func() int {
+		record, err := app.FindRecordById("testing_center_hours", hours.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record.GetInt("seats")
+	}
+
+	seat := createRecord(t, app, "seats", map[string]any{"DisplayName": "A1", "room": room.Id, "status": "disabled"})
+	if got := seats(); got != 2 {
+		t.Fatalf("got %d seats after adding a disabled seat, want 2", got)
+	}
+
+	if err := app.Delete(seat); err != nil {
+		t.Fatal(err)
+	}
+	if got := seats(); got != 3 {
+		t.Fatalf("got %d seats after deleting the disabled seat, want 3", got)
+	}
+}
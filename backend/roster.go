@@ -0,0 +1,188 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/richgrov/testing-center/v2/canvas"
+)
+
+type rosterStudent struct {
+	Student    int64  `json:"student"`
+	Name       string `json:"name"`
+	Enrollment string `json:"enrollment"`
+}
+
+// rosterFailure is a student of the roster whose enrollment couldn't be
+// created or renamed, e.g. because the test is full.
+type rosterFailure struct {
+	Student int64  `json:"student"`
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+}
+
+// rosterSync describes how a test's enrollments changed to match its course
+// roster.
+type rosterSync struct {
+	Added   []rosterStudent `json:"added"`
+	Renamed []rosterStudent `json:"renamed"`
+	// Removed are the enrolled students no longer in the course. Their
+	// enrollments are only deleted if asked to.
+	Removed []rosterStudent `json:"removed"`
+	Failed  []rosterFailure `json:"failed"`
+}
+
+// syncRoster creates an enrollment in the test for every student of the
+// roster who doesn't have one yet and updates the names of the ones who do,
+// matching them on canvas_student_id. Enrollments of students who left the
+// course are deleted if remove is set. Students whose enrollment fails
+// validation are reported and skipped; any other error undoes the whole sync.
+func syncRoster(app core.App, test *core.Record, students []canvas.User, unlockAfter types.DateTime, remove bool) (rosterSync, error) {
+	sync := rosterSync{
+		Added:   []rosterStudent{},
+		Renamed: []rosterStudent{},
+		Removed: []rosterStudent{},
+		Failed:  []rosterFailure{},
+	}
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		enrollments, err := txApp.FindAllRecords("test_enrollments", dbx.HashExp{"test": test.Id})
+		if err != nil {
+			return err
+		}
+
+		existing := make(map[int64]*core.Record, len(enrollments))
+		for _, enrollment := range enrollments {
+			existing[int64(enrollment.GetInt("canvas_student_id"))] = enrollment
+		}
+
+		collection, err := txApp.FindCollectionByNameOrId("test_enrollments")
+		if err != nil {
+			return err
+		}
+
+		inRoster := make(map[int64]bool, len(students))
+		for _, student := range students {
+			inRoster[student.Id] = true
+		}
+
+		// students who left go first, so removing them frees their places for
+		// the ones who joined
+		for _, enrollment := range enrollments {
+			student := int64(enrollment.GetInt("canvas_student_id"))
+			if inRoster[student] {
+				continue
+			}
+
+			sync.Removed = append(sync.Removed, rosterStudent{
+				student,
+				enrollment.GetString("canvas_student_name"),
+				enrollment.Id,
+			})
+
+			if remove {
+				if err := txApp.Delete(enrollment); err != nil {
+					return err
+				}
+			}
+		}
+
+		seen := make(map[int64]bool, len(students))
+		for _, student := range students {
+			if seen[student.Id] {
+				continue
+			}
+			seen[student.Id] = true
+
+			enrollment, ok := existing[student.Id]
+			if ok && enrollment.GetString("canvas_student_name") == student.Name {
+				continue
+			}
+
+			if !ok {
+				enrollment = core.NewRecord(collection)
+				enrollment.Set("test", test.Id)
+				enrollment.Set("canvas_student_id", student.Id)
+				enrollment.Set("unlock_after", unlockAfter)
+			}
+			enrollment.Set("canvas_student_name", student.Name)
+
+			var invalid validation.Errors
+			if err := txApp.Save(enrollment); errors.As(err, &invalid) {
+				sync.Failed = append(sync.Failed, rosterFailure{student.Id, student.Name, invalid.Error()})
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			synced := rosterStudent{student.Id, student.Name, enrollment.Id}
+			if ok {
+				sync.Renamed = append(sync.Renamed, synced)
+			} else {
+				sync.Added = append(sync.Added, synced)
+			}
+		}
+
+		return nil
+	})
+
+	return sync, err
+}
+
+// syncTestRoster returns a handler that enrolls the students of a test's
+// Canvas course in it. The optional JSON body can give the course, if the
+// test doesn't have one, the unlock_after time of new enrollments and whether
+// to remove the students who left the course.
+func syncTestRoster(client *canvas.Client) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if err := requireStaff(e); err != nil {
+			return err
+		}
+
+		if client.Token == "" {
+			return e.Error(http.StatusServiceUnavailable, "canvas token isn't configured", nil)
+		}
+
+		var body struct {
+			CourseId    string         `json:"courseId"`
+			UnlockAfter types.DateTime `json:"unlockAfter"`
+			Remove      bool           `json:"remove"`
+		}
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("invalid body", err)
+		}
+
+		test, err := e.App.FindRecordById("tests", e.Request.PathValue("testId"))
+		if errors.Is(err, sql.ErrNoRows) {
+			return e.NotFoundError("test not found", nil)
+		}
+		if err != nil {
+			return e.InternalServerError("error fetching test", err)
+		}
+
+		courseId := body.CourseId
+		if courseId == "" {
+			courseId = test.GetString("canvas_course_id")
+		}
+		if courseId == "" {
+			return e.BadRequestError("test has no canvas course", nil)
+		}
+
+		students, err := client.CourseStudents(e.Request.Context(), courseId)
+		if err != nil {
+			return e.Error(http.StatusBadGateway, "error fetching course roster", err)
+		}
+
+		sync, err := syncRoster(e.App, test, students, body.UnlockAfter, body.Remove)
+		if err != nil {
+			return e.InternalServerError("error syncing enrollments", err)
+		}
+
+		return e.JSON(http.StatusOK, sync)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/richgrov/testing-center/v2/canvas"
+)
+
+const syncRosterPattern = "POST /api/tests/{testId}/sync-roster"
+
+func TestSyncRoster(t *testing.T) {
+	app := newTestApp(t)
+	staff := createStaff(t, app)
+
+	canvasServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/courses/55/users" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `[{"id":1,"name":"Ann"},{"id":2,"name":"Bob B"},{"id":3,"name":"Cy"}]`)
+	}))
+	defer canvasServer.Close()
+	handler := syncTestRoster(canvas.NewClient(canvasServer.URL+"/api/v1/", "token"))
+
+	// one place is left once Bob and Gone are counted
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "canvas_course_id": "55", "max_enrollments": 3})
+	createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 2, "canvas_student_name": "Bob"})
+	gone := createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 9, "canvas_student_name": "Gone"})
+
+	sync := func(body string) rosterSync {
+		t.Helper()
+
+		response := serve(app, staff, syncRosterPattern, handler, http.MethodPost, "/api/tests/"+test.Id+"/sync-roster", body)
+		if response.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", response.Code, response.Body)
+		}
+
+		var result rosterSync
+		if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	result := sync(`{}`)
+	if len(result.Added) != 1 || result.Added[0].Name != "Ann" {
+		t.Errorf("got added %+v, want Ann", result.Added)
+	}
+	if len(result.Renamed) != 1 || result.Renamed[0].Name != "Bob B" {
+		t.Errorf("got renamed %+v, want Bob B", result.Renamed)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Enrollment != gone.Id {
+		t.Errorf("got removed %+v, want Gone", result.Removed)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Name != "Cy" || result.Failed[0].Reason == "" {
+		t.Errorf("got failed %+v, want Cy with a reason", result.Failed)
+	}
+
+	if _, err := app.FindRecordById("test_enrollments", gone.Id); err != nil {
+		t.Fatalf("enrollment of a student who left was deleted without asking: %v", err)
+	}
+
+	// removing Gone frees a place for Cy in the same sync
+	result = sync(`{"remove":true}`)
+	if len(result.Removed) != 1 || len(result.Added) != 1 || result.Added[0].Name != "Cy" || len(result.Failed) != 0 {
+		t.Fatalf("got %+v, want Gone removed and Cy added", result)
+	}
+	if got := enrollmentCount(t, app, test); got != 3 {
+		t.Fatalf("got %d enrollments counted, want 3", got)
+	}
+}
+
+func TestSyncRosterInvalidStudent(t *testing.T) {
+	app := newTestApp(t)
+	staff := createStaff(t, app)
+
+	// a name longer than the field allows fails validation between two valid
+	// students
+	canvasServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"id":1,"name":"Ann"},{"id":2,"name":%q},{"id":3,"name":"Cy"}]`, strings.Repeat("B", 5001))
+	}))
+	defer canvasServer.Close()
+	handler := syncTestRoster(canvas.NewClient(canvasServer.URL+"/api/v1/", "token"))
+
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "canvas_course_id": "55", "max_enrollments": 3})
+
+	response := serve(app, staff, syncRosterPattern, handler, http.MethodPost, "/api/tests/"+test.Id+"/sync-roster", "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", response.Code, response.Body)
+	}
+
+	var result rosterSync
+	if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added) != 2 || len(result.Failed) != 1 || result.Failed[0].Student != 2 {
+		t.Fatalf("got %+v, want Ann and Cy added and student 2 failing", result)
+	}
+
+	if got := enrollmentCount(t, app, test); got != 2 {
+		t.Fatalf("got %d enrollments counted, want the 2 saved", got)
+	}
+}
+
+func TestSyncRosterCanvasError(t *testing.T) {
+	app := newTestApp(t)
+	staff := createStaff(t, app)
+
+	canvasServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer canvasServer.Close()
+	handler := syncTestRoster(canvas.NewClient(canvasServer.URL+"/api/v1/", "expired"))
+
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "canvas_course_id": "55"})
+
+	response := serve(app, staff, syncRosterPattern, handler, http.MethodPost, "/api/tests/"+test.Id+"/sync-roster", "")
+	if response.Code != http.StatusBadGateway {
+		t.Fatalf("got status %d, want %d", response.Code, http.StatusBadGateway)
+	}
+}
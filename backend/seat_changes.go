@@ -0,0 +1,401 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/richgrov/testing-center/v2/seating"
+)
+
+// highVisibilityWarning is the visibility at which a pair of seated students
+// is flagged after a seat change.
+const highVisibilityWarning = 0.5
+
+var (
+	errSeatOutOfService = errors.New("seat is out of service during this time")
+	errSeatUnsuitable   = errors.New("seat doesn't meet the student's seating requirements")
+	errNoSeat           = errors.New("student has no seat")
+)
+
+type seatChange struct {
+	Enrollment string   `json:"enrollment"`
+	Student    int64    `json:"student"`
+	Seat       string   `json:"seat"`
+	Warnings   []string `json:"warnings"`
+}
+
+// findSeatAssignment returns the enrollment's seat assignment, or nil if it
+// doesn't have one.
+func findSeatAssignment(app core.App, enrollmentId string) (*core.Record, error) {
+	assignment, err := app.FindFirstRecordByFilter(
+		"SeatAssignments",
+		"enrollment = {:enrollment}",
+		dbx.Params{"enrollment": enrollmentId},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return assignment, err
+}
+
+// checkSeatSuitable refuses seats that are disabled during the window or lack
+// a tag the enrollment's student requires.
+func checkSeatSuitable(seatRecord *core.Record, enrollment *core.Record, window timeWindow) error {
+	if seatDisabledDuring(seatRecord, window) {
+		return errSeatOutOfService
+	}
+
+	seat := seating.Seat{
+		Tags:    seatRecord.GetStringSlice("tags"),
+		Proctor: seatRecord.GetBool("proctor_station"),
+	}
+	student := seating.Student{Requirements: enrollment.GetStringSlice("seat_requirements")}
+	if !seat.Satisfies(&student) {
+		return errSeatUnsuitable
+	}
+
+	return nil
+}
+
+// moveToSeat assigns the enrollment to a specific seat, replacing the seat it
+// had before.
+func moveToSeat(app core.App, enrollment *core.Record, seatId string, window timeWindow) error {
+	seatRecord, err := app.FindRecordById("seats", seatId)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSeatSuitable(seatRecord, enrollment, window); err != nil {
+		return err
+	}
+
+	assignment, err := findSeatAssignment(app, enrollment.Id)
+	if err != nil {
+		return err
+	}
+	if assignment == nil {
+		return assignSeat(app, enrollment.Id, seatId, window)
+	}
+
+	assignment.Set("seat", seatId)
+	return app.Save(assignment)
+}
+
+// seatWarnings describes the occupied seats that can see the seat, or be seen
+// from it, at least as well as highVisibilityWarning.
+func seatWarnings(app core.App, seatId string, window timeWindow) ([]string, error) {
+	seats, err := getAllSeats(app, window)
+	if err != nil {
+		return nil, err
+	}
+
+	obstacles, err := getObstacles(app)
+	if err != nil {
+		return nil, err
+	}
+
+	seatAssignments, err := getSeatAssignments(app, window)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := markOccupied(app, seats, seatAssignments, ""); err != nil {
+		return nil, err
+	}
+
+	warnings := []string{}
+	for i, seat := range seats {
+		if seat.Id != seatId {
+			continue
+		}
+
+		for _, pair := range seating.VisiblePairs(i, seats, obstacles, highVisibilityWarning) {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s and %s can see each other (visibility %.2f)",
+				seat.Name, seats[pair.B].Name, pair.Visibility,
+			))
+		}
+	}
+
+	return warnings, nil
+}
+
+func describeSeatChange(app core.App, enrollment *core.Record, window timeWindow) (seatChange, error) {
+	change := seatChange{
+		Enrollment: enrollment.Id,
+		Student:    int64(enrollment.GetInt("canvas_student_id")),
+		Warnings:   []string{},
+	}
+
+	assignment, err := findSeatAssignment(app, enrollment.Id)
+	if err != nil || assignment == nil {
+		return change, err
+	}
+
+	seatRecord, err := app.FindRecordById("seats", assignment.GetString("seat"))
+	if err != nil {
+		return change, err
+	}
+	change.Seat = seatRecord.GetString("DisplayName")
+
+	change.Warnings, err = seatWarnings(app, seatRecord.Id, window)
+	return change, err
+}
+
+// seatChangeError turns the errors of a seat change into API errors.
+func seatChangeError(e *core.RequestEvent, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return e.NotFoundError("seat not found", err)
+	case errors.Is(err, errNoSeat):
+		return e.NotFoundError(err.Error(), nil)
+	case errors.Is(err, errSeatOutOfService), errors.Is(err, errSeatUnsuitable):
+		return e.BadRequestError(err.Error(), nil)
+	case isSeatConflict(err):
+		return e.Error(http.StatusConflict, errSeatTaken.Error(), nil)
+	default:
+		return e.InternalServerError("error changing seat", err)
+	}
+}
+
+// requestEnrollment returns the test enrollment whose id is in the provided
+// path parameter and the time it occupies a seat.
+func requestEnrollment(e *core.RequestEvent, param string) (*core.Record, timeWindow, error) {
+	enrollment, err := e.App.FindRecordById("test_enrollments", e.Request.PathValue(param))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, timeWindow{}, e.NotFoundError("enrollment not found", nil)
+	}
+	if err != nil {
+		return nil, timeWindow{}, e.InternalServerError("error fetching enrollment", err)
+	}
+
+	window, ok := enrollmentWindow(enrollment)
+	if !ok {
+		return nil, timeWindow{}, e.BadRequestError("enrollment has no scheduled start time", nil)
+	}
+
+	return enrollment, window, nil
+}
+
+func releaseSeat(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	enrollment, _, err := requestEnrollment(e, "enrollmentId")
+	if err != nil {
+		return err
+	}
+
+	assignment, err := findSeatAssignment(e.App, enrollment.Id)
+	if err != nil {
+		return e.InternalServerError("error fetching seat assignment", err)
+	}
+	if assignment == nil {
+		return e.NotFoundError(errNoSeat.Error(), nil)
+	}
+
+	if err := e.App.Delete(assignment); err != nil {
+		return e.InternalServerError("error releasing seat", err)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+func moveSeat(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	enrollment, window, err := requestEnrollment(e, "enrollmentId")
+	if err != nil {
+		return err
+	}
+
+	err = e.App.RunInTransaction(func(txApp core.App) error {
+		return moveToSeat(txApp, enrollment, e.Request.PathValue("seatId"), window)
+	})
+	if err != nil {
+		return seatChangeError(e, err)
+	}
+
+	change, err := describeSeatChange(e.App, enrollment, window)
+	if err != nil {
+		return e.InternalServerError("error checking seat", err)
+	}
+
+	return e.JSON(http.StatusOK, change)
+}
+
+// swapSeats exchanges the seats of two enrollments. Both must already have one.
+func swapSeats(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	enrollment, window, err := requestEnrollment(e, "enrollmentId")
+	if err != nil {
+		return err
+	}
+
+	otherEnrollment, otherWindow, err := requestEnrollment(e, "otherEnrollmentId")
+	if err != nil {
+		return err
+	}
+
+	err = e.App.RunInTransaction(func(txApp core.App) error {
+		assignment, err := findSeatAssignment(txApp, enrollment.Id)
+		if err != nil {
+			return err
+		}
+
+		otherAssignment, err := findSeatAssignment(txApp, otherEnrollment.Id)
+		if err != nil {
+			return err
+		}
+
+		if assignment == nil || otherAssignment == nil {
+			return errNoSeat
+		}
+
+		// both seats have to be free before either student can take the other's
+		for _, record := range []*core.Record{assignment, otherAssignment} {
+			if err := txApp.Delete(record); err != nil {
+				return err
+			}
+		}
+
+		if err := moveToSeat(txApp, enrollment, otherAssignment.GetString("seat"), window); err != nil {
+			return err
+		}
+
+		return moveToSeat(txApp, otherEnrollment, assignment.GetString("seat"), otherWindow)
+	})
+	if err != nil {
+		return seatChangeError(e, err)
+	}
+
+	changes := make([]seatChange, 0, 2)
+	for _, swapped := range []struct {
+		enrollment *core.Record
+		window     timeWindow
+	}{{enrollment, window}, {otherEnrollment, otherWindow}} {
+		change, err := describeSeatChange(e.App, swapped.enrollment, swapped.window)
+		if err != nil {
+			return e.InternalServerError("error checking seat", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return e.JSON(http.StatusOK, changes)
+}
+
+// markSeatOutOfService disables a seat and reseats every student assigned to
+// it while it's disabled who hasn't finished yet. The optional from and until
+// times in the body limit it to a maintenance window; without them the seat
+// is disabled until it's set back to active.
+func markSeatOutOfService(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	var body struct {
+		From  types.DateTime `json:"from"`
+		Until types.DateTime `json:"until"`
+	}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("invalid maintenance window", err)
+	}
+	if !body.From.IsZero() && !body.Until.IsZero() && !body.From.Time().Before(body.Until.Time()) {
+		return e.BadRequestError("maintenance window must end after it starts", nil)
+	}
+
+	var displaced []*core.Record
+
+	err := e.App.RunInTransaction(func(txApp core.App) error {
+		seatRecord, err := txApp.FindRecordById("seats", e.Request.PathValue("seatId"))
+		if err != nil {
+			return err
+		}
+
+		seatRecord.Set("status", "disabled")
+		seatRecord.Set("disabled_from", body.From)
+		seatRecord.Set("disabled_until", body.Until)
+		if err := txApp.Save(seatRecord); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		if body.From.Time().After(start) {
+			start = body.From.Time()
+		}
+
+		filter := "seat = {:seat} && ends_at > {:start}"
+		if !body.Until.IsZero() {
+			filter += " && starts_at < {:until}"
+		}
+
+		assignments, err := txApp.FindRecordsByFilter(
+			"SeatAssignments",
+			filter,
+			"starts_at",
+			0,
+			0,
+			dbx.Params{"seat": seatRecord.Id, "start": toDateTime(start), "until": body.Until},
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := expandRecords(txApp, assignments, "enrollment"); err != nil {
+			return err
+		}
+
+		for _, assignment := range assignments {
+			if err := txApp.Delete(assignment); err != nil {
+				return err
+			}
+
+			if enrollment := assignment.ExpandedOne("enrollment"); enrollment != nil {
+				displaced = append(displaced, enrollment)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return seatChangeError(e, err)
+	}
+
+	changes := make([]seatChange, 0, len(displaced))
+	for _, enrollment := range displaced {
+		window, ok := enrollmentWindow(enrollment)
+		if !ok {
+			continue
+		}
+
+		_, err := reserveSeat(e.App, enrollment, window)
+		if err != nil && !errors.Is(err, errNoSeatsAvailable) {
+			return e.InternalServerError("error reseating student", err)
+		}
+
+		change, err := describeSeatChange(e.App, enrollment, window)
+		if err != nil {
+			return e.InternalServerError("error checking seat", err)
+		}
+		if change.Seat == "" {
+			change.Warnings = append(change.Warnings, errNoSeatsAvailable.Error())
+		}
+
+		changes = append(changes, change)
+	}
+
+	return e.JSON(http.StatusOK, changes)
+}
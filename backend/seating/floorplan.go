@@ -0,0 +1,185 @@
+package seating
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func directionToAngle(direction string) (float64, error) {
+	switch direction {
+	case "N":
+		return math.Pi / 2, nil
+	case "S":
+		return -math.Pi / 2, nil
+	case "E":
+		return 0, nil
+	case "W":
+		return math.Pi, nil
+	}
+
+	return 0, fmt.Errorf("invalid direction: %s", direction)
+}
+
+// isTagged reports whether a cell of a tag column marks the seat with the tag.
+func isTagged(cell string) bool {
+	switch strings.ToLower(strings.TrimSpace(cell)) {
+	case "", "0", "n", "no", "false":
+		return false
+	}
+
+	return true
+}
+
+// PROCTOR_COLUMN is the header of the floor plan column marking proctor
+// stations.
+const PROCTOR_COLUMN = "proctor"
+
+// SEAT_TAGS are the tags a seat can have. They match the values of the seats
+// collection's tags field.
+var SEAT_TAGS = []string{"accessible", "near_proctor", "isolated"}
+
+// tagOfColumn returns the seat tag a floor plan column header names.
+func tagOfColumn(header string) (string, error) {
+	header = strings.TrimSpace(header)
+	if strings.EqualFold(header, PROCTOR_COLUMN) {
+		return PROCTOR_COLUMN, nil
+	}
+
+	for _, tag := range SEAT_TAGS {
+		if strings.EqualFold(header, tag) {
+			return tag, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown column %q: expected one of %s or %s", header, strings.Join(SEAT_TAGS, ", "), PROCTOR_COLUMN)
+}
+
+// LoadSeats reads seats from a CSV file with the columns DisplayName, Angle,
+// X and Y. Any further columns are seat tags named by their header, e.g. a
+// seat with "x" in the "accessible" column gets the "accessible" tag, except
+// for a "proctor" column which marks proctor stations. A column that isn't
+// one of SEAT_TAGS or "proctor" is an error.
+func LoadSeats(path string) ([]Seat, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 4 {
+		return nil, fmt.Errorf("expected at least 4 columns, got %d", len(header))
+	}
+
+	tagColumns := make([]string, 0, len(header)-4)
+	for _, column := range header[4:] {
+		tag, err := tagOfColumn(column)
+		if err != nil {
+			return nil, err
+		}
+		tagColumns = append(tagColumns, tag)
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	seats := make([]Seat, 0, len(records))
+
+	for _, record := range records {
+		angle, err := directionToAngle(record[1])
+		if err != nil {
+			return nil, err
+		}
+
+		x, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		var tags []string
+		proctor := false
+		for i, tag := range tagColumns {
+			if !isTagged(record[4+i]) {
+				continue
+			}
+
+			if tag == PROCTOR_COLUMN {
+				proctor = true
+			} else {
+				tags = append(tags, tag)
+			}
+		}
+
+		seats = append(seats, Seat{
+			Name:     record[0],
+			X:        x,
+			Y:        y,
+			Angle:    angle,
+			Occupied: false,
+			Tags:     tags,
+			Proctor:  proctor,
+		})
+	}
+
+	return seats, nil
+}
+
+// LoadObstacles reads the wall segments of a floor plan from a CSV file with
+// the columns X1, Y1, X2 and Y2.
+func LoadObstacles(path string) ([]Obstacle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 4
+
+	_, err = reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	obstacles := make([]Obstacle, 0, len(records))
+
+	for _, record := range records {
+		var coordinates [4]float64
+		for i := range coordinates {
+			coordinates[i], err = strconv.ParseFloat(record[i], 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		obstacles = append(obstacles, Obstacle{
+			X1: coordinates[0],
+			Y1: coordinates[1],
+			X2: coordinates[2],
+			Y2: coordinates[3],
+		})
+	}
+
+	return obstacles, nil
+}
@@ -0,0 +1,51 @@
+package seating
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFloorPlan(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "seats.csv")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadSeatsTags(t *testing.T) {
+	path := writeFloorPlan(t, "DisplayName,Angle,X,Y,Accessible,isolated,proctor\n"+
+		"A1,N,0,0,x,,\n"+
+		"A2,S,1,0,,yes,\n"+
+		"P,E,2,0,,,x\n")
+
+	seats, err := LoadSeats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(seats[0].Tags, []string{"accessible"}) {
+		t.Errorf("A1 has tags %v, want [accessible]", seats[0].Tags)
+	}
+	if !slices.Equal(seats[1].Tags, []string{"isolated"}) {
+		t.Errorf("A2 has tags %v, want [isolated]", seats[1].Tags)
+	}
+	if !seats[2].Proctor || len(seats[2].Tags) > 0 {
+		t.Errorf("P is proctor %v with tags %v, want a proctor station without tags", seats[2].Proctor, seats[2].Tags)
+	}
+}
+
+func TestLoadSeatsUnknownColumn(t *testing.T) {
+	path := writeFloorPlan(t, "DisplayName,Angle,X,Y,window\nA1,N,0,0,x\n")
+
+	_, err := LoadSeats(path)
+	if err == nil || !strings.Contains(err.Error(), `"window"`) {
+		t.Fatalf("got error %v, want one naming the unknown column", err)
+	}
+}
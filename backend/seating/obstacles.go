@@ -0,0 +1,61 @@
+package seating
+
+// Obstacle is a wall segment in a room's floor plan, such as a cubicle
+// divider, that nobody can see through. Polygons like pillars are made of
+// several segments.
+type Obstacle struct {
+	Room string
+	X1   float64
+	Y1   float64
+	X2   float64
+	Y2   float64
+}
+
+// orientation returns which side of the line through a and b the point c is
+// on: positive for left, negative for right and zero if it's on the line.
+func orientation(ax, ay, bx, by, cx, cy float64) float64 {
+	return (bx-ax)*(cy-ay) - (by-ay)*(cx-ax)
+}
+
+func onSegment(ax, ay, bx, by, cx, cy float64) bool {
+	return min(ax, bx) <= cx && cx <= max(ax, bx) && min(ay, by) <= cy && cy <= max(ay, by)
+}
+
+func (o *Obstacle) intersects(x1, y1, x2, y2 float64) bool {
+	d1 := orientation(o.X1, o.Y1, o.X2, o.Y2, x1, y1)
+	d2 := orientation(o.X1, o.Y1, o.X2, o.Y2, x2, y2)
+	d3 := orientation(x1, y1, x2, y2, o.X1, o.Y1)
+	d4 := orientation(x1, y1, x2, y2, o.X2, o.Y2)
+
+	if (d1 > 0 && d2 < 0 || d1 < 0 && d2 > 0) && (d3 > 0 && d4 < 0 || d3 < 0 && d4 > 0) {
+		return true
+	}
+
+	return d1 == 0 && onSegment(o.X1, o.Y1, o.X2, o.Y2, x1, y1) ||
+		d2 == 0 && onSegment(o.X1, o.Y1, o.X2, o.Y2, x2, y2) ||
+		d3 == 0 && onSegment(x1, y1, x2, y2, o.X1, o.Y1) ||
+		d4 == 0 && onSegment(x1, y1, x2, y2, o.X2, o.Y2)
+}
+
+// hasLineOfSight reports whether nothing blocks the sightline between two
+// seats.
+func hasLineOfSight(origin *Seat, target *Seat, obstacles []Obstacle) bool {
+	for i := range obstacles {
+		obstacle := &obstacles[i]
+		if obstacle.Room == origin.Room && obstacle.intersects(origin.X, origin.Y, target.X, target.Y) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// visibilityBetween is visbilityFactor, except that it drops to zero when the
+// sightline is blocked by an obstacle.
+func visibilityBetween(origin *Seat, target *Seat, obstacles []Obstacle) float64 {
+	if !hasLineOfSight(origin, target, obstacles) {
+		return 0
+	}
+
+	return visbilityFactor(origin, target)
+}
@@ -0,0 +1,244 @@
+package seating
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+type Objective int
+
+const (
+	// TotalVisibility minimizes the sum of visibility between every pair of
+	// students in the room and of the penalties for seats proctors don't
+	// cover well.
+	TotalVisibility Objective = iota
+	// WorstVisibility minimizes the single most visible pair of students or
+	// most poorly covered seat.
+	WorstVisibility
+)
+
+const DEFAULT_ITERATIONS_PER_STUDENT = 500
+
+var ErrNotEnoughSeats = errors.New("not enough suitable free seats for roster")
+
+type OptimizeOptions struct {
+	Objective Objective
+	// Iterations is the number of annealing steps to run. Zero picks a
+	// default based on the roster size.
+	Iterations int
+	// Seed makes the result reproducible: the same seats, roster and seed
+	// always produce the same assignment.
+	Seed int64
+}
+
+type optimizer struct {
+	seats     []Seat
+	obstacles []Obstacle
+	// pairVisibility[i][j] is how well seats i and j can see each other,
+	// in both directions, ignoring what test is taken in either.
+	pairVisibility [][]float64
+	// occupants[i] is the roster index sitting in seat i, -1 if it is free
+	// or -2 if it was occupied before optimizing.
+	occupants []int
+	roster    []Student
+	objective Objective
+	// coverage[i] is how well a proctor watches seat i, or -1 if its room
+	// has no proctor station.
+	coverage []float64
+}
+
+func newOptimizer(seats []Seat, obstacles []Obstacle, roster []Student, objective Objective) *optimizer {
+	o := &optimizer{
+		seats:          seats,
+		obstacles:      obstacles,
+		pairVisibility: make([][]float64, len(seats)),
+		occupants:      make([]int, len(seats)),
+		roster:         roster,
+		objective:      objective,
+		coverage:       make([]float64, len(seats)),
+	}
+
+	for i := range seats {
+		o.pairVisibility[i] = make([]float64, len(seats))
+		for j := range seats {
+			if i != j {
+				o.pairVisibility[i][j] = visibilityBetween(&seats[i], &seats[j], obstacles) + visibilityBetween(&seats[j], &seats[i], obstacles)
+			}
+		}
+
+		if coverage, ok := ProctorCoverage(i, seats, obstacles); ok {
+			o.coverage[i] = coverage
+		} else {
+			o.coverage[i] = -1
+		}
+
+		if seats[i].Occupied {
+			o.occupants[i] = -2
+		} else {
+			o.occupants[i] = -1
+		}
+	}
+
+	return o
+}
+
+func (o *optimizer) testAt(seatIdx int) (string, bool) {
+	switch occupant := o.occupants[seatIdx]; occupant {
+	case -1:
+		return "", false
+	case -2:
+		return o.seats[seatIdx].Test, true
+	default:
+		return o.roster[occupant].Test, true
+	}
+}
+
+func (o *optimizer) cost() float64 {
+	total := 0.0
+	worst := 0.0
+
+	for i := range o.seats {
+		testI, ok := o.testAt(i)
+		if !ok {
+			continue
+		}
+
+		if o.occupants[i] >= 0 && o.coverage[i] >= 0 && sharesTest(i, testI, o.seats, o.testAt) {
+			penalty := PROCTOR_COVERAGE_WEIGHT * (1 - o.coverage[i])
+			total += penalty
+			worst = math.Max(worst, penalty)
+		}
+
+		for j := i + 1; j < len(o.seats); j++ {
+			testJ, ok := o.testAt(j)
+			if !ok {
+				continue
+			}
+
+			visibility := o.pairVisibility[i][j] * testWeight(testI, testJ)
+			total += visibility
+			worst = math.Max(worst, visibility)
+		}
+	}
+
+	if o.objective == WorstVisibility {
+		return worst
+	}
+	return total
+}
+
+// placeGreedily seats the roster in order, each in the least visible seat
+// left, the same way students arriving one at a time would be seated.
+// Students with requirements are seated first so they aren't left without a
+// suitable seat.
+func (o *optimizer) placeGreedily() error {
+	seats := make([]Seat, len(o.seats))
+	copy(seats, o.seats)
+
+	order := make([]int, len(o.roster))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return len(o.roster[b].Requirements) - len(o.roster[a].Requirements)
+	})
+
+	for _, studentIdx := range order {
+		student := o.roster[studentIdx]
+		seatIdx := LeastVisibleSeat(seats, o.obstacles, student)
+		if seatIdx == -1 {
+			return ErrNotEnoughSeats
+		}
+
+		seats[seatIdx].Occupied = true
+		seats[seatIdx].Test = student.Test
+		o.occupants[seatIdx] = studentIdx
+	}
+
+	return nil
+}
+
+// canSit reports whether the occupant of seat from may move to seat to.
+func (o *optimizer) canSit(from int, to int) bool {
+	occupant := o.occupants[from]
+	return occupant < 0 || o.seats[to].Satisfies(&o.roster[occupant])
+}
+
+// OptimizeSeating seats every student of a session at once. Seats that are
+// already occupied stay where they are and count towards visibility. Starting
+// from the greedy seating LeastVisibleSeat would produce, it uses simulated
+// annealing to move and swap students while the objective improves.
+//
+// The returned slice holds the index into seats for each roster entry.
+func OptimizeSeating(seats []Seat, obstacles []Obstacle, roster []Student, options OptimizeOptions) ([]int, error) {
+	freeSeats := make([]int, 0, len(seats))
+	for i, seat := range seats {
+		if !seat.Occupied && !seat.Proctor {
+			freeSeats = append(freeSeats, i)
+		}
+	}
+
+	if len(roster) > len(freeSeats) {
+		return nil, ErrNotEnoughSeats
+	}
+
+	o := newOptimizer(seats, obstacles, roster, options.Objective)
+	if err := o.placeGreedily(); err != nil {
+		return nil, err
+	}
+
+	iterations := options.Iterations
+	if iterations == 0 {
+		iterations = DEFAULT_ITERATIONS_PER_STUDENT * len(roster)
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	currentCost := o.cost()
+	bestCost := currentCost
+	best := make([]int, len(o.occupants))
+	copy(best, o.occupants)
+
+	startTemperature := math.Max(currentCost, 1e-9) * 0.1
+	endTemperature := startTemperature * 1e-3
+
+	for step := 0; step < iterations && len(roster) > 0; step++ {
+		progress := float64(step) / float64(iterations)
+		temperature := startTemperature * math.Pow(endTemperature/startTemperature, progress)
+
+		// move a random student's seat to another free seat, swapping with
+		// whoever is already sitting there
+		from := freeSeats[random.Intn(len(freeSeats))]
+		to := freeSeats[random.Intn(len(freeSeats))]
+		if from == to || o.occupants[from] == -1 && o.occupants[to] == -1 {
+			continue
+		}
+		if !o.canSit(from, to) || !o.canSit(to, from) {
+			continue
+		}
+
+		o.occupants[from], o.occupants[to] = o.occupants[to], o.occupants[from]
+		newCost := o.cost()
+		delta := newCost - currentCost
+
+		if delta <= 0 || random.Float64() < math.Exp(-delta/temperature) {
+			currentCost = newCost
+			if currentCost < bestCost {
+				bestCost = currentCost
+				copy(best, o.occupants)
+			}
+		} else {
+			o.occupants[from], o.occupants[to] = o.occupants[to], o.occupants[from]
+		}
+	}
+
+	assignment := make([]int, len(roster))
+	for seatIdx, occupant := range best {
+		if occupant >= 0 {
+			assignment[occupant] = seatIdx
+		}
+	}
+
+	return assignment, nil
+}
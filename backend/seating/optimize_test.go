@@ -0,0 +1,137 @@
+package seating
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// lab is a 4 by 4 grid of desks facing north with a proctor station at the
+// front, the two front corner desks accessible.
+func lab() []Seat {
+	seats := []Seat{{Name: "P", X: 45, Y: -30, Angle: 0, Proctor: true}}
+	for row := 0; row < 4; row++ {
+		for column := 0; column < 4; column++ {
+			seat := Seat{Name: fmt.Sprintf("%c%d", 'A'+row, column+1), X: float64(column) * 30, Y: float64(row) * 30}
+			if row == 0 && (column == 0 || column == 3) {
+				seat.Tags = []string{"accessible"}
+			}
+			seats = append(seats, seat)
+		}
+	}
+
+	return seats
+}
+
+func labRoster() []Student {
+	return []Student{
+		{Test: "CSC101"},
+		{Test: "CSC101", Requirements: []string{"accessible"}},
+		{Test: "CSC101"},
+		{Test: "CSC101"},
+		{Test: "MTH201"},
+		{Test: "MTH201", Requirements: []string{"accessible"}},
+		{Test: "MTH201"},
+		{Test: "PHY110"},
+	}
+}
+
+// seatingCost is the objective of the assignment, as the optimizer scores it.
+func seatingCost(seats []Seat, obstacles []Obstacle, roster []Student, objective Objective, assignment []int) float64 {
+	o := newOptimizer(seats, obstacles, roster, objective)
+	for student, seatIdx := range assignment {
+		o.occupants[seatIdx] = student
+	}
+
+	return o.cost()
+}
+
+func TestOptimizeSeatingSeed(t *testing.T) {
+	first, err := OptimizeSeating(lab(), nil, labRoster(), OptimizeOptions{Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := OptimizeSeating(lab(), nil, labRoster(), OptimizeOptions{Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(first, second) {
+		t.Fatalf("got %v and %v from the same seed", first, second)
+	}
+}
+
+func TestOptimizeSeatingConstraints(t *testing.T) {
+	seats := lab()
+	seats[6].Occupied = true
+	seats[6].Test = "CSC101"
+	roster := labRoster()
+
+	for _, objective := range []Objective{TotalVisibility, WorstVisibility} {
+		assignment, err := OptimizeSeating(seats, nil, roster, OptimizeOptions{Objective: objective, Seed: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		taken := make(map[int]bool)
+		for student, seatIdx := range assignment {
+			seat := seats[seatIdx]
+			if seat.Proctor {
+				t.Errorf("objective %d: student %d seated at the proctor station", objective, student)
+			}
+			if seat.Occupied {
+				t.Errorf("objective %d: student %d seated in occupied seat %s", objective, student, seat.Name)
+			}
+			if !seat.Satisfies(&roster[student]) {
+				t.Errorf("objective %d: student %d seated in %s without %v", objective, student, seat.Name, roster[student].Requirements)
+			}
+			if taken[seatIdx] {
+				t.Errorf("objective %d: seat %s given out twice", objective, seat.Name)
+			}
+			taken[seatIdx] = true
+		}
+	}
+}
+
+func TestOptimizeSeatingBeatsGreedy(t *testing.T) {
+	seats := lab()
+	roster := labRoster()
+
+	for _, objective := range []Objective{TotalVisibility, WorstVisibility} {
+		greedy := newOptimizer(seats, nil, roster, objective)
+		if err := greedy.placeGreedily(); err != nil {
+			t.Fatal(err)
+		}
+		greedyCost := greedy.cost()
+
+		for seed := int64(1); seed <= 5; seed++ {
+			assignment, err := OptimizeSeating(seats, nil, roster, OptimizeOptions{Objective: objective, Seed: seed})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cost := seatingCost(seats, nil, roster, objective, assignment); cost > greedyCost+1e-9 {
+				t.Errorf("objective %d, seed %d: cost %v is worse than the greedy %v", objective, seed, cost, greedyCost)
+			}
+		}
+	}
+}
+
+func TestOptimizeSeatingNotEnoughSeats(t *testing.T) {
+	tooMany := make([]Student, 17)
+	if _, err := OptimizeSeating(lab(), nil, tooMany, OptimizeOptions{}); !errors.Is(err, ErrNotEnoughSeats) {
+		t.Errorf("got error %v seating 17 students at 16 desks, want ErrNotEnoughSeats", err)
+	}
+
+	// only two desks are accessible
+	needy := []Student{
+		{Requirements: []string{"accessible"}},
+		{Requirements: []string{"accessible"}},
+		{Requirements: []string{"accessible"}},
+	}
+	if _, err := OptimizeSeating(lab(), nil, needy, OptimizeOptions{}); !errors.Is(err, ErrNotEnoughSeats) {
+		t.Errorf("got error %v seating 3 students at 2 accessible desks, want ErrNotEnoughSeats", err)
+	}
+}
@@ -0,0 +1,92 @@
+package seating
+
+import "math"
+
+// Seats that are poorly covered by a proctor are penalized by up to this much
+// on top of their visibility when students taking the same test share the room.
+const PROCTOR_COVERAGE_WEIGHT = 0.5
+
+// A proctor watches a whole room, so their view fades over a much longer
+// distance than a student's.
+const PROCTOR_RANGE = 12.0 * 20
+
+// proctorView is how well a proctor station watches a seat: the proctor has
+// to face the seat, and like between students, the seat's screen has to be
+// turned away from them.
+func proctorView(proctor *Seat, seat *Seat, obstacles []Obstacle) float64 {
+	if proctor.Room != seat.Room || !hasLineOfSight(proctor, seat, obstacles) {
+		return 0
+	}
+
+	xDiff := seat.X - proctor.X
+	yDiff := seat.Y - proctor.Y
+	distanceFactor := math.Exp(-math.Hypot(xDiff, yDiff) / PROCTOR_RANGE)
+
+	return distanceFactor * facingFactor(xDiff, yDiff, proctor.Angle) * facingFactor(xDiff, yDiff, seat.Angle)
+}
+
+// ProctorCoverage returns how well the best placed proctor station in the
+// seat's room watches it, from 0 to 1. ok is false if the room has no proctor
+// station.
+func ProctorCoverage(seatIdx int, seats []Seat, obstacles []Obstacle) (coverage float64, ok bool) {
+	seat := &seats[seatIdx]
+
+	for i := range seats {
+		proctor := &seats[i]
+		if !proctor.Proctor || i == seatIdx || proctor.Room != seat.Room {
+			continue
+		}
+
+		coverage = math.Max(coverage, proctorView(proctor, seat, obstacles))
+		ok = true
+	}
+
+	return coverage, ok
+}
+
+// sharesTest reports whether anyone seated in the seat's room is taking the
+// test. testAt returns the test taken in a seat, with ok false if it's free.
+func sharesTest(seatIdx int, test string, seats []Seat, testAt func(seatIdx int) (test string, ok bool)) bool {
+	for i := range seats {
+		if i == seatIdx || seats[i].Room != seats[seatIdx].Room {
+			continue
+		}
+
+		if other, ok := testAt(i); ok && sameTest(other, test) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// occupantTest returns the test taken in one of the seats, with ok false if
+// it's free.
+func occupantTest(seats []Seat) func(seatIdx int) (string, bool) {
+	return func(seatIdx int) (string, bool) {
+		return seats[seatIdx].Test, seats[seatIdx].Occupied
+	}
+}
+
+// uncoveredPenalty is what seating a student taking the test in a seat costs
+// for how poorly a proctor covers it. There's no penalty when nobody else in
+// the room takes the same test or the room has no proctor station.
+func uncoveredPenalty(seatIdx int, test string, seats []Seat, obstacles []Obstacle) float64 {
+	if !sharesTest(seatIdx, test, seats, occupantTest(seats)) {
+		return 0
+	}
+
+	coverage, ok := ProctorCoverage(seatIdx, seats, obstacles)
+	if !ok {
+		return 0
+	}
+
+	return PROCTOR_COVERAGE_WEIGHT * (1 - coverage)
+}
+
+// SeatScore is what LeastVisibleSeat minimizes: how visible a seat would be
+// to the occupied seats if its student took the provided test, plus a
+// penalty for how poorly proctors cover it.
+func SeatScore(seatIdx int, test string, seats []Seat, obstacles []Obstacle) float64 {
+	return SeatVisibility(seatIdx, test, seats, obstacles) + uncoveredPenalty(seatIdx, test, seats, obstacles)
+}
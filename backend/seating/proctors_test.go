@@ -0,0 +1,30 @@
+package seating
+
+import "testing"
+
+func TestSharesTest(t *testing.T) {
+	seats := []Seat{
+		{Room: "lab", Occupied: true, Test: "CSC101"},
+		{Room: "lab", Occupied: true, Test: "MTH201"},
+		{Room: "hall", Occupied: true, Test: "PHY110"},
+		{Room: "lab"},
+		{Room: "lab", Occupied: true},
+	}
+
+	tests := []struct {
+		test string
+		want bool
+	}{
+		{"CSC101", true},
+		{"MTH201", true},
+		{"PHY110", false},
+		{"ENG100", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := sharesTest(3, tt.test, seats, occupantTest(seats)); got != tt.want {
+			t.Errorf("sharesTest(%q) = %v, want %v", tt.test, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package seating
+
+import (
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// SeatFromRecord returns the seat a record of the seats collection describes.
+// It starts out free.
+func SeatFromRecord(record *core.Record) Seat {
+	return Seat{
+		Id:      record.Id,
+		Room:    record.GetString("room"),
+		Name:    record.GetString("DisplayName"),
+		X:       record.GetFloat("X"),
+		Y:       record.GetFloat("Y"),
+		Angle:   record.GetFloat("Angle"),
+		Tags:    record.GetStringSlice("tags"),
+		Proctor: record.GetBool("proctor_station"),
+	}
+}
+
+// SeatDisabledDuring reports whether a record of the seats collection is
+// disabled at any point between start and end. A disabled seat without a from
+// or until time is disabled indefinitely in that direction.
+func SeatDisabledDuring(seat *core.Record, start time.Time, end time.Time) bool {
+	if seat.GetString("status") != "disabled" {
+		return false
+	}
+
+	from := seat.GetDateTime("disabled_from")
+	until := seat.GetDateTime("disabled_until")
+	return (from.IsZero() || from.Time().Before(end)) && (until.IsZero() || until.Time().After(start))
+}
+
+// ObstacleFromRecord returns the obstacle a record of the obstacles
+// collection describes.
+func ObstacleFromRecord(record *core.Record) Obstacle {
+	return Obstacle{
+		Room: record.GetString("room"),
+		X1:   record.GetFloat("X1"),
+		Y1:   record.GetFloat("Y1"),
+		X2:   record.GetFloat("X2"),
+		Y2:   record.GetFloat("Y2"),
+	}
+}
+
+// CourseOf returns the course identifier of an enrollment's test, built from
+// the test's course code and section. The enrollment must have "test"
+// expanded.
+func CourseOf(enrollment *core.Record) string {
+	if enrollment == nil {
+		return ""
+	}
+
+	test := enrollment.ExpandedOne("test")
+	if test == nil {
+		return ""
+	}
+
+	course := test.GetString("course_code")
+	if section := test.GetString("section"); section != "" {
+		course += "-" + section
+	}
+
+	return course
+}
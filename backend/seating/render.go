@@ -0,0 +1,252 @@
+package seating
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const CHART_SIZE = 1024.0
+const CHART_MARGIN = 40.0
+const SEAT_RADIUS = 10.0
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartInk        = color.RGBA{20, 20, 20, 255}
+	lowVisibility   = color.RGBA{80, 180, 90, 255}
+	highVisibility  = color.RGBA{220, 50, 40, 255}
+)
+
+// SeatScores returns how visible each seat is to the occupied seats, on the
+// scale of SeatVisibility. Free seats are scored as if their student took the
+// same test as each occupied seat that sees them, which is the worst case.
+// Proctor stations score zero.
+func SeatScores(seats []Seat, obstacles []Obstacle) []float64 {
+	scores := make([]float64, len(seats))
+
+	for i := range seats {
+		if seats[i].Proctor {
+			continue
+		}
+
+		if seats[i].Occupied {
+			scores[i] = SeatVisibility(i, seats[i].Test, seats, obstacles)
+			continue
+		}
+
+		for j := range seats {
+			if i != j && seats[j].Occupied {
+				visibility := visibilityBetween(&seats[j], &seats[i], obstacles) * SAME_TEST_WEIGHT
+				scores[i] = math.Max(scores[i], visibility)
+			}
+		}
+	}
+
+	return scores
+}
+
+// chartLayout maps floor plan coordinates onto the chart.
+type chartLayout struct {
+	minX   float64
+	minY   float64
+	scale  float64
+	width  int
+	height int
+}
+
+func newChartLayout(seats []Seat, obstacles []Obstacle) chartLayout {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	extend := func(x, y float64) {
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	for _, seat := range seats {
+		extend(seat.X, seat.Y)
+	}
+	for _, obstacle := range obstacles {
+		extend(obstacle.X1, obstacle.Y1)
+		extend(obstacle.X2, obstacle.Y2)
+	}
+
+	if math.IsInf(minX, 1) {
+		minX, minY, maxX, maxY = 0, 0, 1, 1
+	}
+
+	span := math.Max(math.Max(maxX-minX, maxY-minY), 1)
+	scale := (CHART_SIZE - 2*CHART_MARGIN) / span
+
+	return chartLayout{
+		minX:   minX,
+		minY:   minY,
+		scale:  scale,
+		width:  int((maxX-minX)*scale + 2*CHART_MARGIN),
+		height: int((maxY-minY)*scale + 2*CHART_MARGIN),
+	}
+}
+
+func (l *chartLayout) point(x, y float64) (float64, float64) {
+	return (x-l.minX)*l.scale + CHART_MARGIN, (y-l.minY)*l.scale + CHART_MARGIN
+}
+
+// seatColors scales the scores relative to the most visible seat so the
+// differences within a room show up even when every score is small. Proctor
+// stations are drawn in ink.
+func seatColors(seats []Seat, obstacles []Obstacle) []color.RGBA {
+	scores := SeatScores(seats, obstacles)
+
+	highest := 0.0
+	for _, score := range scores {
+		highest = math.Max(highest, score)
+	}
+
+	colors := make([]color.RGBA, len(seats))
+	for i, score := range scores {
+		t := 0.0
+		if highest > 0 {
+			t = score / highest
+		}
+		if seats[i].Proctor {
+			colors[i] = chartInk
+		} else {
+			colors[i] = lerpRgba(lowVisibility, highVisibility, t)
+		}
+	}
+
+	return colors
+}
+
+func drawLine(img *image.RGBA, x1, y1, x2, y2 float64, width float64, col color.Color) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+	for step := 0; step <= steps; step++ {
+		t := float64(step) / float64(steps)
+		fillCircle(img, x1+t*(x2-x1), y1+t*(y2-y1), width/2, col)
+	}
+}
+
+func fillCircle(img *image.RGBA, cx, cy, radius float64, col color.Color) {
+	for y := int(cy - radius); y <= int(cy+radius); y++ {
+		for x := int(cx - radius); x <= int(cx+radius); x++ {
+			if math.Hypot(float64(x)-cx, float64(y)-cy) <= radius {
+				img.Set(x, y, col)
+			}
+		}
+	}
+}
+
+func drawLabel(img *image.RGBA, x, y float64, label string) {
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(chartInk),
+		Face: basicfont.Face7x13,
+	}
+	width := drawer.MeasureString(label).Round()
+	drawer.Dot = fixed.P(int(x)-width/2, int(y))
+	drawer.DrawString(label)
+}
+
+// RenderPNG draws a floor plan: obstacles as walls, every seat colored from
+// green to red by how visible it is, with a line showing which way it faces
+// and its name below. Occupied seats are drawn with a dark ring.
+func RenderPNG(seats []Seat, obstacles []Obstacle) *image.RGBA {
+	layout := newChartLayout(seats, obstacles)
+	img := image.NewRGBA(image.Rect(0, 0, layout.width, layout.height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+
+	for _, obstacle := range obstacles {
+		x1, y1 := layout.point(obstacle.X1, obstacle.Y1)
+		x2, y2 := layout.point(obstacle.X2, obstacle.Y2)
+		drawLine(img, x1, y1, x2, y2, 4, chartInk)
+	}
+
+	colors := seatColors(seats, obstacles)
+	for i, seat := range seats {
+		x, y := layout.point(seat.X, seat.Y)
+
+		if seat.Occupied {
+			fillCircle(img, x, y, SEAT_RADIUS+3, chartInk)
+		}
+		fillCircle(img, x, y, SEAT_RADIUS, colors[i])
+
+		facingX := x + math.Cos(seat.Angle)*SEAT_RADIUS*1.8
+		facingY := y + math.Sin(seat.Angle)*SEAT_RADIUS*1.8
+		drawLine(img, x, y, facingX, facingY, 2, chartInk)
+
+		drawLabel(img, x, y+SEAT_RADIUS+16, seat.Name)
+	}
+
+	return img
+}
+
+// RenderSVG writes the same chart as RenderPNG as an SVG document. The
+// document is built in memory and written at once.
+func RenderSVG(w io.Writer, seats []Seat, obstacles []Obstacle) error {
+	layout := newChartLayout(seats, obstacles)
+
+	var svg bytes.Buffer
+	fmt.Fprintf(
+		&svg,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		layout.width, layout.height, layout.width, layout.height,
+	)
+
+	fmt.Fprintf(&svg, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hexColor(chartBackground))
+
+	for _, obstacle := range obstacles {
+		x1, y1 := layout.point(obstacle.X1, obstacle.Y1)
+		x2, y2 := layout.point(obstacle.X2, obstacle.Y2)
+		fmt.Fprintf(
+			&svg,
+			`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="4" stroke-linecap="round"/>`+"\n",
+			x1, y1, x2, y2, hexColor(chartInk),
+		)
+	}
+
+	colors := seatColors(seats, obstacles)
+	for i, seat := range seats {
+		x, y := layout.point(seat.X, seat.Y)
+
+		stroke := "none"
+		if seat.Occupied {
+			stroke = hexColor(chartInk)
+		}
+
+		fmt.Fprintf(
+			&svg,
+			`<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s" stroke="%s" stroke-width="4"/>`+"\n",
+			x, y, SEAT_RADIUS, hexColor(colors[i]), stroke,
+		)
+		fmt.Fprintf(
+			&svg,
+			`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n",
+			x, y,
+			x+math.Cos(seat.Angle)*SEAT_RADIUS*1.8,
+			y+math.Sin(seat.Angle)*SEAT_RADIUS*1.8,
+			hexColor(chartInk),
+		)
+		fmt.Fprintf(
+			&svg,
+			`<text x="%.1f" y="%.1f" text-anchor="middle" fill="%s">%s</text>`+"\n",
+			x, y+SEAT_RADIUS+16, hexColor(chartInk), html.EscapeString(seat.Name),
+		)
+	}
+
+	fmt.Fprintln(&svg, "</svg>")
+
+	_, err := w.Write(svg.Bytes())
+	return err
+}
+
+func hexColor(col color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", col.R, col.G, col.B)
+}
@@ -0,0 +1,66 @@
+package seating
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// twoSeats returns an occupied seat facing a free one 24 units to its east.
+func twoSeats() []Seat {
+	return []Seat{
+		{Name: "A1", X: 0, Y: 0, Angle: math.Pi, Occupied: true, Test: "CSC101"},
+		{Name: "A2", X: 24, Y: 0, Angle: 0},
+	}
+}
+
+func TestSeatScoresObstacles(t *testing.T) {
+	open := SeatScores(twoSeats(), nil)
+	if open[1] <= 0 {
+		t.Fatalf("free seat in view scored %v, want more than 0", open[1])
+	}
+
+	wall := []Obstacle{{X1: 12, Y1: -10, X2: 12, Y2: 10}}
+	blocked := SeatScores(twoSeats(), wall)
+	if blocked[1] != 0 {
+		t.Errorf("free seat behind a wall scored %v, want 0", blocked[1])
+	}
+
+	// the wall is in floor plan coordinates, so one off to the side doesn't
+	// block anything
+	aside := []Obstacle{{X1: 12, Y1: 20, X2: 12, Y2: 40}}
+	if score := SeatScores(twoSeats(), aside)[1]; score != open[1] {
+		t.Errorf("free seat with a wall off to the side scored %v, want %v", score, open[1])
+	}
+}
+
+func TestSeatScoresScale(t *testing.T) {
+	seats := twoSeats()
+	free := SeatScores(seats, nil)[1]
+
+	seats[1].Occupied = true
+	seats[1].Test = "CSC101"
+	same := SeatScores(seats, nil)[1]
+
+	seats[1].Test = "MTH201"
+	different := SeatScores(seats, nil)[1]
+
+	if math.Abs(free-same) > 1e-9 {
+		t.Errorf("free seat scored %v, want the worst case of a seat sharing the test, %v", free, same)
+	}
+	if want := same * DIFFERENT_TEST_WEIGHT / SAME_TEST_WEIGHT; math.Abs(different-want) > 1e-9 {
+		t.Errorf("seat with another test scored %v, want %v", different, want)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRenderSVGWriteError(t *testing.T) {
+	if err := RenderSVG(failingWriter{}, twoSeats(), nil); err == nil {
+		t.Fatal("got no error writing to a failing writer")
+	}
+}
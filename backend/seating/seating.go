@@ -1,19 +1,58 @@
 package seating
 
 import (
-	"image"
+	"cmp"
 	"image/color"
 	"math"
+	"slices"
 )
 
 const DISTANCE_SCALE_DIVISOR = 12.0 * 2
 
+// Sightlines between students taking the same test are what cheating risk
+// actually comes from, so they count far more than ones between unrelated tests.
+const SAME_TEST_WEIGHT = 1.0
+const DIFFERENT_TEST_WEIGHT = 0.2
+
 type Seat struct {
+	Id       string
+	Room     string
 	Name     string
 	X        float64
 	Y        float64
 	Angle    float64
 	Occupied bool
+	// Test identifies the test the seat's occupant is taking.
+	Test string
+	// Tags describe what the seat offers, e.g. "accessible" for a
+	// wheelchair-accessible desk.
+	Tags []string
+	// Proctor marks a proctor station. Students are never seated there; its
+	// position and angle are where a proctor watches the room from.
+	Proctor bool
+}
+
+// Student describes who is being seated.
+type Student struct {
+	Test string
+	// Requirements lists tags the student's seat must have.
+	Requirements []string
+}
+
+// Satisfies reports whether the student can sit in the seat: it has to have
+// every tag the student requires and not be a proctor station.
+func (seat *Seat) Satisfies(student *Student) bool {
+	if seat.Proctor {
+		return false
+	}
+
+	for _, requirement := range student.Requirements {
+		if !slices.Contains(seat.Tags, requirement) {
+			return false
+		}
+	}
+
+	return true
 }
 
 func rotatePoint(x, y, theta float64) (float64, float64) {
@@ -23,30 +62,82 @@ func rotatePoint(x, y, theta float64) (float64, float64) {
 }
 
 func visbilityFactor(origin *Seat, target *Seat) float64 {
+	// every room has its own coordinate space and walls in between
+	if origin.Room != target.Room {
+		return 0
+	}
+
 	xDiff := target.X - origin.X
 	yDiff := target.Y - origin.Y
 	distance := math.Hypot(xDiff*xDiff, yDiff*yDiff) / DISTANCE_SCALE_DIVISOR
 	distanceFactor := math.Exp(-distance)
 
-	rotatedX, rotatedY := rotatePoint(xDiff, yDiff, target.Angle)
+	return distanceFactor * facingFactor(xDiff, yDiff, target.Angle)
+}
+
+// facingFactor is 1 when the offset points the same way as the angle, falling
+// to 0 when it points the opposite way.
+func facingFactor(xDiff, yDiff, angle float64) float64 {
+	rotatedX, rotatedY := rotatePoint(xDiff, yDiff, angle)
 	relativeAngle := math.Abs(math.Atan2(rotatedY, rotatedX))
-	angleFactor := (math.Pi - relativeAngle) / math.Pi
-	return distanceFactor * angleFactor
+	return (math.Pi - relativeAngle) / math.Pi
+}
+
+// sameTest reports whether two students are taking the same test. Students
+// whose test isn't known aren't assumed to share one.
+func sameTest(a string, b string) bool {
+	return a != "" && a == b
 }
 
-func seatVisibility(seatIdx int, allSeats []Seat) float64 {
-	highestVisibility := 0.0
+func testWeight(a string, b string) float64 {
+	if sameTest(a, b) {
+		return SAME_TEST_WEIGHT
+	}
+	return DIFFERENT_TEST_WEIGHT
+}
+
+// Contribution is how visible a seat is to one occupied seat.
+type Contribution struct {
+	// Seat is the index of the occupied seat.
+	Seat       int
+	Visibility float64
+}
+
+// Contributions returns how visible a seat would be to each occupied seat
+// that can see it if its student took the provided test, most visible first.
+func Contributions(seatIdx int, test string, allSeats []Seat, obstacles []Obstacle) []Contribution {
+	var contributions []Contribution
 	seat := allSeats[seatIdx]
+	seat.Test = test
 
 	for i, other := range allSeats {
 		if !other.Occupied || i == seatIdx {
 			continue
 		}
 
-		highestVisibility = math.Max(highestVisibility, visbilityFactor(&other, &seat))
+		visibility := visibilityBetween(&other, &seat, obstacles) * testWeight(other.Test, seat.Test)
+		if visibility > 0 {
+			contributions = append(contributions, Contribution{i, visibility})
+		}
+	}
+
+	slices.SortStableFunc(contributions, func(a, b Contribution) int {
+		return cmp.Compare(b.Visibility, a.Visibility)
+	})
+
+	return contributions
+}
+
+// SeatVisibility is how visible a seat would be to the occupied seats if its
+// student took the provided test: the visibility of the occupied seat that can
+// see it best.
+func SeatVisibility(seatIdx int, test string, allSeats []Seat, obstacles []Obstacle) float64 {
+	contributions := Contributions(seatIdx, test, allSeats, obstacles)
+	if len(contributions) == 0 {
+		return 0
 	}
 
-	return highestVisibility
+	return contributions[0].Visibility
 }
 
 func lerpRgba(start, end color.RGBA, t float64) color.RGBA {
@@ -65,44 +156,89 @@ func lerpRgba(start, end color.RGBA, t float64) color.RGBA {
 	return color.RGBA{R: r, G: g, B: b, A: a}
 }
 
-func BuildHeatmap() *image.RGBA {
-	img := image.NewRGBA(image.Rectangle{image.Point{0, 0}, image.Point{512, 512}})
-	seat := Seat{
-		X: 1.0,
-		Y: 1.0,
-	}
+// LeastVisibleSeat returns the index of the free seat satisfying the
+// student's requirements with the lowest SeatScore, or -1 if there is no such
+// seat. Sightlines through obstacles don't count.
+func LeastVisibleSeat(seats []Seat, obstacles []Obstacle, student Student) int {
+	best := -1
+	lowestScore := math.MaxFloat64
 
-	for x := 0; x < 512; x++ {
-		for y := 0; y < 512; y++ {
-			start := color.RGBA{50, 8, 58, 255}
-			end := color.RGBA{99, 56, 22, 255}
-			target := Seat{
-				X: float64(x) / 256.0,
-				Y: float64(y) / 256.0,
-			}
-			col := lerpRgba(start, end, visbilityFactor(&seat, &target))
-			img.Set(x, y, col)
+	for i, seat := range seats {
+		if seat.Occupied || !seat.Satisfies(&student) {
+			continue
+		}
+
+		score := SeatScore(i, student.Test, seats, obstacles)
+		if score < lowestScore {
+			best = i
+			lowestScore = score
 		}
 	}
 
-	return img
+	return best
 }
 
-func LeastVisibleSeat(seats []Seat) int {
-	best := -1
-	lowestVisibility := math.MaxFloat64
+// Candidate is a free seat and its SeatScore.
+type Candidate struct {
+	Seat  int
+	Score float64
+}
 
+// RankSeats returns the free seats satisfying the student's requirements from
+// the lowest to the highest SeatScore. The first one is the seat
+// LeastVisibleSeat picks.
+func RankSeats(seats []Seat, obstacles []Obstacle, student Student) []Candidate {
+	var candidates []Candidate
 	for i, seat := range seats {
-		if seat.Occupied {
+		if seat.Occupied || !seat.Satisfies(&student) {
 			continue
 		}
 
-		visibility := seatVisibility(i, seats)
-		if visibility < lowestVisibility {
-			best = i
-			lowestVisibility = visibility
+		candidates = append(candidates, Candidate{i, SeatScore(i, student.Test, seats, obstacles)})
+	}
+
+	slices.SortStableFunc(candidates, func(a, b Candidate) int {
+		return cmp.Compare(a.Score, b.Score)
+	})
+
+	return candidates
+}
+
+// Pair is two occupied seats and how well the students in them can see each
+// other.
+type Pair struct {
+	A          int
+	B          int
+	Visibility float64
+}
+
+// VisiblePairs returns the occupied seats that can see, or be seen from, the
+// occupied seat at seatIdx at least as well as the threshold, most visible
+// first. Visibility in each direction is weighted by whether the students
+// share a test, and the higher direction is reported.
+func VisiblePairs(seatIdx int, seats []Seat, obstacles []Obstacle, threshold float64) []Pair {
+	var pairs []Pair
+	seat := &seats[seatIdx]
+
+	for i := range seats {
+		other := &seats[i]
+		if !other.Occupied || i == seatIdx {
+			continue
+		}
+
+		weight := testWeight(seat.Test, other.Test)
+		visibility := math.Max(
+			visibilityBetween(seat, other, obstacles)*weight,
+			visibilityBetween(other, seat, obstacles)*weight,
+		)
+		if visibility >= threshold {
+			pairs = append(pairs, Pair{seatIdx, i, visibility})
 		}
 	}
 
-	return best
+	slices.SortStableFunc(pairs, func(a, b Pair) int {
+		return cmp.Compare(b.Visibility, a.Visibility)
+	})
+
+	return pairs
 }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/richgrov/testing-center/v2/seating"
+)
+
+const seatReservationAttempts = 5
+
+var (
+	errSeatTaken        = errors.New("seat is already assigned during this time")
+	errNoSeatsAvailable = errors.New("no seats available")
+)
+
+// seatDisabledDuring reports whether the seat is disabled at any point during
+// the window.
+func seatDisabledDuring(seat *core.Record, window timeWindow) bool {
+	return seating.SeatDisabledDuring(seat, window.Start, window.End)
+}
+
+// getAllSeats returns every seat that can be assigned during the window,
+// leaving out the ones disabled for any part of it.
+func getAllSeats(app core.App, window timeWindow) ([]seating.Seat, error) {
+	seatCollection, err := app.FindCollectionByNameOrId("seats")
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := app.FindAllRecords(seatCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	seats := make([]seating.Seat, 0, len(records))
+	for _, record := range records {
+		if seatDisabledDuring(record, window) {
+			continue
+		}
+
+		seats = append(seats, seating.SeatFromRecord(record))
+	}
+
+	return seats, nil
+}
+
+func getObstacles(app core.App) ([]seating.Obstacle, error) {
+	records, err := app.FindAllRecords("obstacles")
+	if err != nil {
+		return nil, err
+	}
+
+	obstacles := make([]seating.Obstacle, 0, len(records))
+	for _, record := range records {
+		obstacles = append(obstacles, seating.ObstacleFromRecord(record))
+	}
+
+	return obstacles, nil
+}
+
+// getSeatAssignments returns the seat assignments whose time window overlaps
+// the provided one.
+func getSeatAssignments(app core.App, window timeWindow) ([]*core.Record, error) {
+	return app.FindRecordsByFilter(
+		"SeatAssignments",
+		"starts_at < {:end} && ends_at > {:start}",
+		"",
+		0,
+		0,
+		dbx.Params{"start": toDateTime(window.Start), "end": toDateTime(window.End)},
+	)
+}
+
+// checkSeatAvailable refuses to save a seat assignment that overlaps another
+// assignment of the same seat. The check and the write share a transaction so
+// two writers can't both take the seat, whether or not they went through
+// reserveSeat. The unique index on seat and starts_at only catches
+// assignments that start at the same time.
+func checkSeatAvailable(e *core.RecordEvent) error {
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+
+		window := timeWindow{
+			e.Record.GetDateTime("starts_at").Time(),
+			e.Record.GetDateTime("ends_at").Time(),
+		}
+
+		conflicts, err := txApp.FindRecordsByFilter(
+			"SeatAssignments",
+			"id != {:id} && seat = {:seat} && starts_at < {:end} && ends_at > {:start}",
+			"",
+			1,
+			0,
+			dbx.Params{
+				"id":    e.Record.Id,
+				"seat":  e.Record.GetString("seat"),
+				"start": toDateTime(window.Start),
+				"end":   toDateTime(window.End),
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return errSeatTaken
+		}
+
+		return e.Next()
+	})
+}
+
+func isSeatConflict(err error) bool {
+	if errors.Is(err, errSeatTaken) {
+		return true
+	}
+
+	var fieldErrors validation.Errors
+	if !errors.As(err, &fieldErrors) {
+		return false
+	}
+
+	for _, fieldErr := range fieldErrors {
+		var validationErr validation.Error
+		if errors.As(fieldErr, &validationErr) && validationErr.Code() == "validation_not_unique" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// studentOf describes the student of an enrollment for seating. The
+// enrollment must have "test" expanded.
+func studentOf(enrollment *core.Record) seating.Student {
+	return seating.Student{
+		Test:         seating.CourseOf(enrollment),
+		Requirements: enrollment.GetStringSlice("seat_requirements"),
+	}
+}
+
+func expandRecords(app core.App, records []*core.Record, expands ...string) error {
+	for _, err := range app.ExpandRecords(records, expands, nil) {
+		return err
+	}
+
+	return nil
+}
+
+// markOccupied marks the seats assigned to anyone but the excluded enrollment
+// as occupied by the test their student is taking.
+func markOccupied(app core.App, seats []seating.Seat, seatAssignments []*core.Record, excludeEnrollment string) error {
+	if err := expandRecords(app, seatAssignments, "enrollment.test"); err != nil {
+		return err
+	}
+
+	for _, assignment := range seatAssignments {
+		if assignment.GetString("enrollment") == excludeEnrollment {
+			continue
+		}
+
+		for i := range seats {
+			if seats[i].Id == assignment.GetString("seat") {
+				seats[i].Occupied = true
+				seats[i].Test = seating.CourseOf(assignment.ExpandedOne("enrollment"))
+			}
+		}
+	}
+
+	return nil
+}
+
+func assignSeat(app core.App, enrollmentId string, seatId string, window timeWindow) error {
+	seatAssignmentsCollection, err := app.FindCollectionByNameOrId("SeatAssignments")
+	if err != nil {
+		return err
+	}
+
+	record := core.NewRecord(seatAssignmentsCollection)
+	record.Set("enrollment", enrollmentId)
+	record.Set("seat", seatId)
+	record.Set("starts_at", toDateTime(window.Start))
+	record.Set("ends_at", toDateTime(window.End))
+
+	if err = app.Save(record); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// pickSeat returns the seat assigned to the enrollment. If it doesn't have one
+// yet, a room is chosen first and then the least visible free seat in it is
+// assigned.
+func pickSeat(app core.App, enrollment *core.Record, window timeWindow) (seating.Seat, error) {
+	seats, err := getAllSeats(app, window)
+	if err != nil {
+		return seating.Seat{}, err
+	}
+
+	obstacles, err := getObstacles(app)
+	if err != nil {
+		return seating.Seat{}, err
+	}
+
+	seatAssignments, err := getSeatAssignments(app, window)
+	if err != nil {
+		return seating.Seat{}, err
+	}
+
+	if err := expandRecords(app, seatAssignments, "enrollment.test"); err != nil {
+		return seating.Seat{}, err
+	}
+	if err := expandRecords(app, []*core.Record{enrollment}, "test"); err != nil {
+		return seating.Seat{}, err
+	}
+
+	for _, assignment := range seatAssignments {
+		otherSeat := assignment.GetString("seat")
+
+		for i, seat := range seats {
+			if seat.Id != otherSeat {
+				continue
+			}
+
+			if assignment.GetString("enrollment") == enrollment.Id {
+				return seat, nil
+			}
+			seats[i].Occupied = true
+			seats[i].Test = seating.CourseOf(assignment.ExpandedOne("enrollment"))
+		}
+	}
+
+	student := studentOf(enrollment)
+	var preferredRoom string
+	if test := enrollment.ExpandedOne("test"); test != nil {
+		preferredRoom = test.GetString("room")
+	}
+
+	room, ok, err := chooseRoom(app, seats, student, preferredRoom)
+	if err != nil {
+		return seating.Seat{}, err
+	}
+	if !ok {
+		return seating.Seat{}, errNoSeatsAvailable
+	}
+
+	roomSeats := seatsInRoom(seats, room)
+	seatIdx := seating.LeastVisibleSeat(roomSeats, obstacles, student)
+	if seatIdx == -1 {
+		return seating.Seat{}, errNoSeatsAvailable
+	}
+
+	seat := roomSeats[seatIdx]
+	if err := assignSeat(app, enrollment.Id, seat.Id, window); err != nil {
+		return seating.Seat{}, err
+	}
+
+	return seat, nil
+}
+
+// reserveSeat runs pickSeat in a single transaction so concurrent requests
+// can't both be handed the same free seat. If another writer claims the seat
+// first anyway, the reservation is retried against the updated assignments.
+func reserveSeat(app core.App, enrollment *core.Record, window timeWindow) (seating.Seat, error) {
+	var seat seating.Seat
+	var err error
+
+	for attempt := 0; attempt < seatReservationAttempts; attempt++ {
+		err = app.RunInTransaction(func(txApp core.App) error {
+			var pickErr error
+			seat, pickErr = pickSeat(txApp, enrollment, window)
+			return pickErr
+		})
+		if !isSeatConflict(err) {
+			break
+		}
+	}
+
+	return seat, err
+}
+
+// seatAssignment assigns a seat to a test enrollment that is within its
+// check-in window and responds with its name, or with an explanation of the
+// choice when the format query parameter is json.
+func seatAssignment(e *core.RequestEvent) error {
+	if err := requireStaff(e); err != nil {
+		return err
+	}
+
+	enrollment, window, err := requestEnrollment(e, "enrollmentId")
+	if err != nil {
+		return err
+	}
+
+	if !checkInWindow(window).contains(time.Now()) {
+		return e.BadRequestError("enrollment isn't within its check-in window", nil)
+	}
+	if !enrollment.GetDateTime("checked_out_at").IsZero() {
+		return e.BadRequestError("student has already checked out", nil)
+	}
+
+	seat, err := reserveSeat(e.App, enrollment, window)
+	if errors.Is(err, errNoSeatsAvailable) {
+		return e.NoContent(204)
+	}
+	if err != nil {
+		return e.InternalServerError("error assigning seat", err)
+	}
+
+	if e.Request.URL.Query().Get("format") != "json" {
+		return e.String(http.StatusOK, seat.Name)
+	}
+
+	alternatives := defaultExplainedAlternatives
+	if param := e.Request.URL.Query().Get("alternatives"); param != "" {
+		alternatives, err = strconv.Atoi(param)
+		if err != nil || alternatives < 0 {
+			return e.BadRequestError("invalid number of alternatives", err)
+		}
+	}
+
+	explanation, err := explainSeat(e.App, enrollment, seat, window, alternatives)
+	if err != nil {
+		return e.InternalServerError("error explaining seat", err)
+	}
+
+	return e.JSON(http.StatusOK, explanation)
+}
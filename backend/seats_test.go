@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestSeatAssignmentConcurrentRequests(t *testing.T) {
+	const (
+		seats              = 20
+		enrollments        = 50
+		requestsPerStudent = 5
+	)
+
+	// the race only shows when requests really run in parallel
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(max(runtime.NumCPU(), 8)))
+
+	app := newTestApp(t)
+	staff := createStaff(t, app)
+
+	now := time.Now()
+	createRecord(t, app, "testing_center_hours", map[string]any{
+		"opens":  now.Add(-time.Hour),
+		"closes": now.Add(3 * time.Hour),
+		"seats":  enrollments,
+	})
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "duration_mins": 60})
+
+	for i := 0; i < seats; i++ {
+		createRecord(t, app, "seats", map[string]any{"DisplayName": fmt.Sprint("S", i), "X": i % 5, "Y": i / 5})
+	}
+
+	// staggered starts, so the unique index on seat and starts_at can't stop
+	// overlapping assignments
+	ids := make([]string, enrollments)
+	for i := range ids {
+		ids[i] = createRecord(t, app, "test_enrollments", map[string]any{
+			"test":              test.Id,
+			"canvas_student_id": i + 1,
+			"start_test_at":     now.Add(-5*time.Minute - time.Duration(i)*time.Second),
+			"duration_mins":     60,
+		}).Id
+	}
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, enrollments*requestsPerStudent)
+	for i := 0; i < enrollments*requestsPerStudent; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			response := serve(app, staff, "GET /api/seat-assignment/{enrollmentId}", seatAssignment, "GET", "/api/seat-assignment/"+id, "")
+			statuses <- response.Code
+		}(ids[i%enrollments])
+	}
+	wg.Wait()
+	close(statuses)
+
+	for status := range statuses {
+		if status != http.StatusOK && status != http.StatusNoContent {
+			t.Errorf("got status %d, want 200 or 204", status)
+		}
+	}
+
+	assignments, err := app.FindAllRecords("SeatAssignments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(assignments) != seats {
+		t.Errorf("got %d seat assignments, want every one of the %d seats taken", len(assignments), seats)
+	}
+
+	seated := make(map[string]bool)
+	bySeat := make(map[string][]*core.Record)
+	for _, assignment := range assignments {
+		if seated[assignment.GetString("enrollment")] {
+			t.Errorf("enrollment %s was given more than one seat", assignment.GetString("enrollment"))
+		}
+		seated[assignment.GetString("enrollment")] = true
+		bySeat[assignment.GetString("seat")] = append(bySeat[assignment.GetString("seat")], assignment)
+	}
+
+	for seat, assigned := range bySeat {
+		for i, a := range assigned {
+			for _, b := range assigned[i+1:] {
+				windowA := timeWindow{a.GetDateTime("starts_at").Time(), a.GetDateTime("ends_at").Time()}
+				windowB := timeWindow{b.GetDateTime("starts_at").Time(), b.GetDateTime("ends_at").Time()}
+				if windowA.overlaps(windowB) {
+					t.Errorf("seat %s was assigned to overlapping enrollments %s and %s", seat, a.GetString("enrollment"), b.GetString("enrollment"))
+				}
+			}
+		}
+	}
+}
+
+func TestCheckSeatAvailableRejectsOverlap(t *testing.T) {
+	app := newTestApp(t)
+
+	seat := createRecord(t, app, "seats", map[string]any{"DisplayName": "A1"})
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101"})
+	first := createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 1})
+	second := createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 2})
+
+	start := time.Now().Truncate(time.Minute)
+	createRecord(t, app, "SeatAssignments", map[string]any{
+		"enrollment": first.Id,
+		"seat":       seat.Id,
+		"starts_at":  start,
+		"ends_at":    start.Add(time.Hour),
+	})
+
+	// starts at a different time, so the unique index doesn't catch it
+	_, err := newRecord(app, "SeatAssignments", map[string]any{
+		"enrollment": second.Id,
+		"seat":       seat.Id,
+		"starts_at":  start.Add(30 * time.Minute),
+		"ends_at":    start.Add(90 * time.Minute),
+	})
+	if !isSeatConflict(err) {
+		t.Fatalf("got error %v, want a seat conflict", err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/routine"
+	"github.com/pocketbase/pocketbase/tools/subscriptions"
+)
+
+// examTimersTopic is the realtime topic proctors subscribe to for timer
+// events.
+const examTimersTopic = "exam-timers"
+
+// longestExam bounds how far back to look for students who haven't checked
+// in but are within their planned test time.
+const longestExam = 24 * time.Hour
+
+type examTimer struct {
+	attendance
+	MinutesLeft int `json:"minutesLeft"`
+}
+
+// timerEvent is published to examTimersTopic. Every minute a "timers" event
+// lists every running timer; "warning" and "overtime" events list the timers
+// that just reached a warning or ran out.
+type timerEvent struct {
+	Type   string      `json:"type"`
+	Timers []examTimer `json:"timers"`
+}
+
+// examTimers tracks which warnings were already published so each is only
+// sent once per enrollment.
+type examTimers struct {
+	mu sync.Mutex
+	// warnings are the minutes left at which to warn, e.g. 10 and 5.
+	warnings []int
+	// sent holds the warnings already published for each enrollment, with -1
+	// standing for the overtime event.
+	sent map[string]map[int]bool
+}
+
+func newExamTimers(warnings []int) *examTimers {
+	return &examTimers{warnings: warnings, sent: make(map[string]map[int]bool)}
+}
+
+// findActiveEnrollments returns the enrollments of students who are checked
+// in, or haven't checked in but are within their planned test time, and
+// haven't checked out.
+func findActiveEnrollments(app core.App, now time.Time) ([]*core.Record, error) {
+	enrollments, err := app.FindRecordsByFilter(
+		"test_enrollments",
+		"checked_out_at = '' && (checked_in_at != '' || (start_test_at <= {:now} && start_test_at > {:since}))",
+		"",
+		0,
+		0,
+		dbx.Params{"now": toDateTime(now), "since": toDateTime(now.Add(-longestExam))},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]*core.Record, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		window, ok := enrollmentWindow(enrollment)
+		checkedIn := !enrollment.GetDateTime("checked_in_at").IsZero()
+		if ok && (checkedIn || window.contains(now)) {
+			active = append(active, enrollment)
+		}
+	}
+
+	return active, nil
+}
+
+func getExamTimers(app core.App, now time.Time) ([]examTimer, error) {
+	enrollments, err := findActiveEnrollments(app, now)
+	if err != nil {
+		return nil, err
+	}
+
+	attendances, err := getAttendance(app, enrollments, now)
+	if err != nil {
+		return nil, err
+	}
+
+	timers := make([]examTimer, 0, len(attendances))
+	for _, attendance := range attendances {
+		left := attendance.EndsAt.Time().Sub(now)
+		timers = append(timers, examTimer{attendance, int(math.Ceil(left.Minutes()))})
+	}
+
+	slices.SortStableFunc(timers, func(a, b examTimer) int {
+		return a.EndsAt.Time().Compare(b.EndsAt.Time())
+	})
+
+	return timers, nil
+}
+
+// due returns the timers that reached a warning or ran out since the last
+// call and forgets the enrollments that are no longer timed.
+func (t *examTimers) due(timers []examTimer) (warned []examTimer, overtime []examTimer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	running := make(map[string]bool, len(timers))
+	for _, timer := range timers {
+		running[timer.Enrollment] = true
+
+		sent, ok := t.sent[timer.Enrollment]
+		if !ok {
+			sent = make(map[int]bool)
+			t.sent[timer.Enrollment] = sent
+		}
+
+		if timer.Overtime {
+			if !sent[-1] {
+				sent[-1] = true
+				overtime = append(overtime, timer)
+			}
+			continue
+		}
+
+		// a student who shows up with little time left gets one warning for
+		// every threshold they are already past
+		warn := false
+		for _, minutes := range t.warnings {
+			if timer.MinutesLeft <= minutes && !sent[minutes] {
+				sent[minutes] = true
+				warn = true
+			}
+		}
+		if warn {
+			warned = append(warned, timer)
+		}
+	}
+
+	for enrollment := range t.sent {
+		if !running[enrollment] {
+			delete(t.sent, enrollment)
+		}
+	}
+
+	return warned, overtime
+}
+
+// publishTimerEvents sends the events, in order, to every proctor and admin
+// subscribed to examTimersTopic.
+func publishTimerEvents(app core.App, events []timerEvent) error {
+	messages := make([]subscriptions.Message, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, subscriptions.Message{Name: examTimersTopic, Data: data})
+	}
+
+	for _, client := range app.SubscriptionsBroker().Clients() {
+		if !client.HasSubscription(examTimersTopic) {
+			continue
+		}
+
+		auth, _ := client.Get(apis.RealtimeClientAuthKey).(*core.Record)
+		if !isStaffRecord(auth) {
+			continue
+		}
+
+		// a slow client mustn't hold up the others
+		routine.FireAndForget(func() {
+			for _, message := range messages {
+				client.Send(message)
+			}
+		})
+	}
+
+	return nil
+}
+
+// tick publishes the running timers along with any new warnings and
+// overtime students.
+func (t *examTimers) tick(app core.App, now time.Time) error {
+	timers, err := getExamTimers(app, now)
+	if err != nil {
+		return err
+	}
+
+	warned, overtime := t.due(timers)
+
+	var events []timerEvent
+	if len(warned) > 0 {
+		events = append(events, timerEvent{"warning", warned})
+	}
+	if len(overtime) > 0 {
+		events = append(events, timerEvent{"overtime", overtime})
+	}
+	events = append(events, timerEvent{"timers", timers})
+
+	return publishTimerEvents(app, events)
+}
+
+// bindExamTimers publishes timer events every minute, warning at each of the
+// provided minutes left.
+func bindExamTimers(app core.App, warnings []int) {
+	timers := newExamTimers(warnings)
+
+	app.Cron().MustAdd("examTimers", "* * * * *", func() {
+		if err := timers.tick(app, time.Now()); err != nil {
+			app.Logger().Error("error publishing exam timers", "error", err)
+		}
+	})
+}
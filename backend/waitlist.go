@@ -0,0 +1,287 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+)
+
+// waitlistHold is how long a slot offered to a waitlisted student is kept
+// for them before it goes to the next student.
+const waitlistHold = 30 * time.Minute
+
+var (
+	errHoursNotBookable = validation.NewError("validation_hours_not_bookable", "The test can't be taken during these hours")
+	errSlotsStillOpen   = validation.NewError("validation_slots_open", "There are still open slots during these hours")
+)
+
+// getHoursSlots returns the slots the enrollment could book that fall within
+// the testing center hours.
+func getHoursSlots(app core.App, enrollment *core.Record, hours *core.Record, now time.Time) ([]bookingSlot, error) {
+	slots, err := getBookingSlots(app, enrollment, defaultSlotGranularity, now)
+	if err != nil {
+		return nil, err
+	}
+
+	window := timeWindow{hours.GetDateTime("opens").Time(), hours.GetDateTime("closes").Time()}
+
+	inHours := make([]bookingSlot, 0, len(slots))
+	for _, slot := range slots {
+		if !slot.Start.Time().Before(window.Start) && !slot.End.Time().After(window.End) {
+			inHours = append(inHours, slot)
+		}
+	}
+
+	return inHours, nil
+}
+
+// joinWaitlist queues a student for testing center hours that are full for
+// their test. Hours that still have a slot they can book are refused.
+//
+// Students don't sign in, so the collection's rules only let them see, join
+// and leave the waitlist for the enrollment named in their X-Enrollment
+// header. That is the id from their booking link, which stays a secret since
+// only staff can list enrollments.
+func joinWaitlist(e *core.RecordEvent) error {
+	e.Record.Set("status", "waiting")
+	e.Record.Set("offered_start", "")
+	e.Record.Set("offered_end", "")
+	e.Record.Set("hold_expires_at", "")
+
+	enrollment, err := e.App.FindRecordById("test_enrollments", e.Record.GetString("enrollment"))
+	if errors.Is(err, sql.ErrNoRows) {
+		// left to the relation field's validation
+		return e.Next()
+	}
+	if err != nil {
+		return err
+	}
+
+	hours, err := e.App.FindRecordById("testing_center_hours", e.Record.GetString("hours"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e.Next()
+	}
+	if err != nil {
+		return err
+	}
+
+	test, err := e.App.FindRecordById("tests", enrollment.GetString("test"))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	span := bookableSpan(test, enrollment, now)
+	window := timeWindow{hours.GetDateTime("opens").Time(), hours.GetDateTime("closes").Time()}
+	if !span.overlaps(window) || window.End.Sub(span.Start) < enrollmentDuration(enrollment) {
+		return validation.Errors{"hours": errHoursNotBookable}
+	}
+
+	slots, err := getHoursSlots(e.App, enrollment, hours, now)
+	if err != nil {
+		return err
+	}
+	if len(slots) > 0 {
+		return validation.Errors{"hours": errSlotsStillOpen}
+	}
+
+	return e.Next()
+}
+
+// expireWaitlist ends the holds that ran out and the waits for hours that are
+// over.
+func expireWaitlist(app core.App, now time.Time) error {
+	entries, err := app.FindRecordsByFilter(
+		"waitlist",
+		"(status = 'offered' && hold_expires_at <= {:now}) || (status = 'waiting' && hours.closes <= {:now})",
+		"",
+		0,
+		0,
+		dbx.Params{"now": toDateTime(now)},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entry.Set("status", "expired")
+		if err := app.Save(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// offerWaitlistSlots expires old holds, then offers the earliest free slot in
+// their hours to each waiting student, longest waiting first, holding it for
+// them for waitlistHold.
+func offerWaitlistSlots(app core.App, now time.Time) error {
+	if err := expireWaitlist(app, now); err != nil {
+		return err
+	}
+
+	entries, err := app.FindRecordsByFilter("waitlist", "status = 'waiting'", "created", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := expandRecords(app, entries, "enrollment", "hours"); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		enrollment := entry.ExpandedOne("enrollment")
+		hours := entry.ExpandedOne("hours")
+		if enrollment == nil || hours == nil || !enrollment.GetDateTime("checked_in_at").IsZero() {
+			continue
+		}
+
+		// holds offered earlier in the loop are saved, so they already count
+		// against these slots
+		slots, err := getHoursSlots(app, enrollment, hours, now)
+		if err != nil {
+			return err
+		}
+		if len(slots) == 0 {
+			continue
+		}
+
+		entry.Set("status", "offered")
+		entry.Set("offered_start", slots[0].Start)
+		entry.Set("offered_end", slots[0].End)
+		entry.Set("hold_expires_at", toDateTime(now.Add(waitlistHold)))
+		if err := app.Save(entry); err != nil {
+			return err
+		}
+
+		notifyWaitlistOffer(app, entry, enrollment)
+	}
+
+	return nil
+}
+
+// notifyWaitlistOffer tells the student about the slot held for them on
+// their booking page, and by email if they left an address. A failed email is
+// logged; the offer stands either way.
+func notifyWaitlistOffer(app core.App, entry *core.Record, enrollment *core.Record) {
+	offer := fmt.Sprintf(
+		"A slot starting %s opened up and is being held for you until %s.",
+		entry.GetDateTime("offered_start").Time().Format(time.RFC1123),
+		entry.GetDateTime("hold_expires_at").Time().Format(time.RFC1123),
+	)
+
+	notifyStudent(app, enrollment.Id, studentNotification{"waitlist_offer", offer})
+
+	email := entry.GetString("email")
+	if email == "" {
+		return
+	}
+
+	meta := app.Settings().Meta
+	link := strings.TrimRight(meta.AppURL, "/") + "/test_slot/" + enrollment.Id
+
+	message := &mailer.Message{
+		From:    mail.Address{Name: meta.SenderName, Address: meta.SenderAddress},
+		To:      []mail.Address{{Address: email}},
+		Subject: "A testing center slot opened up",
+		HTML: fmt.Sprintf(
+			"<p>Hello %s,</p><p>%s</p><p><a href=\"%s\">Book it here</a> before then or it goes to the next student on the waitlist.</p>",
+			html.EscapeString(enrollment.GetString("canvas_student_name")),
+			offer,
+			link,
+		),
+	}
+
+	if err := app.NewMailClient().Send(message); err != nil {
+		app.Logger().Error("error sending waitlist offer", "error", err, "waitlist", entry.Id)
+	}
+}
+
+// acceptWaitlistOffers marks the enrollment's waitlist entries whose hours
+// its booking now falls in as accepted.
+func acceptWaitlistOffers(app core.App, enrollment *core.Record) error {
+	window, ok := enrollmentWindow(enrollment)
+	if !ok {
+		return nil
+	}
+
+	entries, err := app.FindRecordsByFilter(
+		"waitlist",
+		"enrollment = {:enrollment} && (status = 'waiting' || status = 'offered') && hours.opens <= {:start} && hours.closes >= {:end}",
+		"",
+		0,
+		0,
+		dbx.Params{
+			"enrollment": enrollment.Id,
+			"start":      toDateTime(window.Start),
+			"end":        toDateTime(window.End),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entry.Set("status", "accepted")
+		if err := app.Save(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateWaitlist settles the waitlist after a booking is made, moved or
+// cancelled: the student's own entries are accepted and any freed slot is
+// offered.
+func updateWaitlist(e *core.RecordEvent) error {
+	changed := bookingChanged(e.Record)
+	if err := e.Next(); err != nil || !changed {
+		return err
+	}
+
+	// the booking is saved by now, so failing here would only hide that from
+	// the student; the cron job offers the slots again a minute later
+	if err := acceptWaitlistOffers(e.App, e.Record); err != nil {
+		e.App.Logger().Error("error accepting waitlist offers", "error", err, "enrollment", e.Record.Id)
+	}
+	if err := offerWaitlistSlots(e.App, time.Now()); err != nil {
+		e.App.Logger().Error("error offering waitlist slots", "error", err)
+	}
+
+	return nil
+}
+
+// reofferCancelledSlot offers the slot of a deleted enrollment to the
+// waitlist.
+func reofferCancelledSlot(e *core.RecordEvent) error {
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	if err := offerWaitlistSlots(e.App, time.Now()); err != nil {
+		e.App.Logger().Error("error offering waitlist slots", "error", err)
+	}
+
+	return nil
+}
+
+// bindWaitlist offers slots to waiting students every minute, which also
+// passes on the ones whose hold ran out.
+func bindWaitlist(app core.App) {
+	app.Cron().MustAdd("waitlistOffers", "* * * * *", func() {
+		if err := offerWaitlistSlots(app, time.Now()); err != nil {
+			app.Logger().Error("error offering waitlist slots", "error", err)
+		}
+	})
+}
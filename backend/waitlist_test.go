@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestWaitlistRules(t *testing.T) {
+	f := newBookingFixture(t)
+	f.addHours(t, 8, 9, 1)
+	if err := f.book(1, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	hours, err := f.app.FindFirstRecordByFilter("testing_center_hours", "seats = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment := createRecord(t, f.app, "test_enrollments", map[string]any{"test": f.test.Id, "canvas_student_id": 2})
+	other := createRecord(t, f.app, "test_enrollments", map[string]any{"test": f.test.Id, "canvas_student_id": 3})
+	entry := createRecord(t, f.app, "waitlist", map[string]any{"enrollment": enrollment.Id, "hours": hours.Id})
+
+	waitlist, err := f.app.FindCollectionByNameOrId("waitlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staff := createStaff(t, f.app)
+	joining := map[string]any{"enrollment": enrollment.Id, "hours": hours.Id}
+	tests := []struct {
+		name string
+		info core.RequestInfo
+		want bool
+	}{
+		{"anonymous", core.RequestInfo{Body: joining}, false},
+		{"another student", core.RequestInfo{Headers: map[string]string{"x_enrollment": other.Id}, Body: joining}, false},
+		{"the student", core.RequestInfo{Headers: map[string]string{"x_enrollment": enrollment.Id}, Body: joining}, true},
+		{"staff", core.RequestInfo{Auth: staff, Body: joining}, true},
+	}
+
+	for _, tt := range tests {
+		for rule, accessRule := range map[string]*string{"create": waitlist.CreateRule, "view": waitlist.ViewRule, "delete": waitlist.DeleteRule} {
+			ok, err := f.app.CanAccessRecord(entry, &tt.info, accessRule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Errorf("%s: got %v for the %s rule, want %v", tt.name, ok, rule, tt.want)
+			}
+		}
+	}
+}
+
+// The waitlist rules trust the enrollment id in the X-Enrollment header, so
+// only staff can list enrollments and see other students' ids.
+func TestEnrollmentListRule(t *testing.T) {
+	app := newTestApp(t)
+	test := createRecord(t, app, "tests", map[string]any{"name": "Midterm", "course_code": "CSC101", "duration_mins": 60})
+	enrollment := createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 1})
+	other := createRecord(t, app, "test_enrollments", map[string]any{"test": test.Id, "canvas_student_id": 2})
+
+	enrollments, err := app.FindCollectionByNameOrId("test_enrollments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	student := createRecord(t, app, "users", map[string]any{"email": "student@example.com", "password": "password123", "role": "student"})
+
+	tests := []struct {
+		name string
+		info core.RequestInfo
+		want bool
+	}{
+		{"anonymous", core.RequestInfo{}, false},
+		{"another student", core.RequestInfo{Headers: map[string]string{"x_enrollment": enrollment.Id}}, false},
+		{"a signed in student", core.RequestInfo{Auth: student}, false},
+		{"staff", core.RequestInfo{Auth: createStaff(t, app)}, true},
+	}
+
+	for _, tt := range tests {
+		ok, err := app.CanAccessRecord(other, &tt.info, enrollments.ListRule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("%s: got %v from the list rule, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+type timeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (w timeWindow) overlaps(other timeWindow) bool {
+	return w.Start.Before(other.End) && other.Start.Before(w.End)
+}
+
+func (w timeWindow) contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+func toDateTime(t time.Time) types.DateTime {
+	dateTime, _ := types.ParseDateTime(t)
+	return dateTime
+}
+
+// checkInEarly is how long before their test starts students can check in.
+const checkInEarly = 15 * time.Minute
+
+// checkInWindow returns when a student can check in for a test taking up the
+// provided window: from shortly before it starts until it ends.
+func checkInWindow(window timeWindow) timeWindow {
+	return timeWindow{window.Start.Add(-checkInEarly), window.End}
+}
+
+func enrollmentDuration(enrollment *core.Record) time.Duration {
+	return time.Duration(enrollment.GetFloat("duration_mins") * float64(time.Minute))
+}
+
+// enrollmentWindow returns the time span a test enrollment occupies in the
+// testing center: from when the student checked in until their personal end
+// time once they have, otherwise the planned span. ok is false if the student
+// hasn't picked a start time yet.
+func enrollmentWindow(enrollment *core.Record) (window timeWindow, ok bool) {
+	checkedIn := enrollment.GetDateTime("checked_in_at")
+	if !checkedIn.IsZero() {
+		return timeWindow{checkedIn.Time(), enrollment.GetDateTime("ends_at").Time()}, true
+	}
+
+	start := enrollment.GetDateTime("start_test_at")
+	if start.IsZero() {
+		return timeWindow{}, false
+	}
+
+	return timeWindow{start.Time(), start.Time().Add(enrollmentDuration(enrollment))}, true
+}
@@ -2,7 +2,6 @@ import { BrowserRouter, Route, Routes } from "react-router";
 
 import Layout from "./pages/Layout";
 import EditTestSlotPage from "./pages/EditTestSlot";
-import TestEnrollmentFabricator from "./pages/christian_scratchpad/TestEnrollmentFabricator";
 import LinkSender from "./pages/christian_scratchpad/LinkSender";
 import CrudeSchedulingExporter from "./pages/christian_scratchpad/CrudeSchedulingExporter";
 import EmailExtractor from "./pages/christian_scratchpad/EmailExtractor";
@@ -23,10 +22,6 @@ export default function App() {
             path="christian_scratchpad"
             element={<ChristianScratchpadLayout />}
           >
-            <Route
-              path="test_enrollment_fabricator"
-              element={<TestEnrollmentFabricator />}
-            />
             <Route path="link_sender" element={<LinkSender />} />
             <Route
               path="crude_scheduling_exporter"
@@ -24,8 +24,7 @@ export function defaultHeatmapColorFunc(value: number, min: number, max: number)
 export default function VerticalSchedulingTimeline(props: SchedulingTimelineProps) {
   const {
     title,
-    allowedWindows,
-    existingSchedules,
+    slots,
     duration,
     timelineStartMins,
     timelineEndMins,
@@ -50,43 +49,10 @@ export default function VerticalSchedulingTimeline(props: SchedulingTimelineProp
     (_, i) => timelineStartMins + (i * cellDurationMins)
   );
 
-  // Check if a time is within allowed windows
-  const isTimeAllowed = (time: number) => {
-    return allowedWindows.some(window => 
-      time >= window.start && time + cellDurationMins <= window.end
-    );
-  };
-
-  // Count overlaps for a specific time
-  const getOverlapCount = (time: number) => {
-    let sum = 0;
-    existingSchedules.filter(schedule => 
-      time < schedule.end && time + cellDurationMins > schedule.start
-    ).forEach(schedule => {sum += schedule.weight});
-    return sum as number;
-  };
-
-  const getMaxOverlap = (time: number) => {
-    let sum = 0;
-    allowedWindows.filter(window => 
-      time >= window.start && time + cellDurationMins <= window.end
-    ).forEach(window => {sum += window.seats});
-    return sum;
-  }
-
-  // Check if a new scheduling would exceed max overlap at any point
-  const wouldExceedMaxOverlap = (startTime: number) => {
-    for (let time = startTime; time < startTime + duration; time += cellDurationMins) {
-      if (isTimeAllowed(time)) {
-        const currentOverlaps = getOverlapCount(time);
-        const maxOverlap = getMaxOverlap(time);
-        if (currentOverlaps >= maxOverlap) {
-          return true;
-        }
-      }
-    }
-    return false;
-  };
+  // The server only offers start times that fit in the hours and leave a
+  // seat for the whole test
+  const slotAt = (time: number) => slots.find(slot => slot.start === time);
+  const maxRemaining = Math.max(1, ...slots.map(slot => slot.remaining));
 
   // Format time for display
   const formatTime = (minutes: number) => {
@@ -98,7 +64,7 @@ export default function VerticalSchedulingTimeline(props: SchedulingTimelineProp
 
   // Preview scheduling
   const getPreviewScheduling = () => {
-    if (!hoverTime || !isTimeAllowed(hoverTime)) return null;
+    if (hoverTime === null || !slotAt(hoverTime)) return null;
     return {
       start: hoverTime,
       end: hoverTime + duration
@@ -108,31 +74,13 @@ export default function VerticalSchedulingTimeline(props: SchedulingTimelineProp
   const preview = getPreviewScheduling();
 
   const handleScheduleClick = (time: number) => {
-    if (!isTimeAllowed(time)) {
-      setError('This time slot is not within allowed windows');
-      return;
-    }
-
-    if (wouldExceedMaxOverlap(time)) {
-      setError(`Cannot schedule here - would exceed maximum overlap`);
+    if (!slotAt(time)) {
+      setError('There are no seats left starting at this time');
       return;
     }
 
     setError(null);
-
-    let myDuration = 0;
-    while (myDuration < duration && isTimeAllowed(time + myDuration)) {
-      myDuration += cellDurationMins;
-    }
-    if (myDuration === 0) {
-      setError("Duration of 0");
-      return;
-    }
-
-    update({
-      startMins: time,
-      durationMins: myDuration,
-    })
+    update(time);
   };
 
   return (
@@ -147,33 +95,28 @@ export default function VerticalSchedulingTimeline(props: SchedulingTimelineProp
       
       <div className="grid">
         {timeSlots.map((time, i) => {
-          const isAllowed = isTimeAllowed(time);
-          const overlaps = getOverlapCount(time);
+          const slot = slotAt(time);
           const isInPreview = preview && time >= preview.start && time < preview.end;
-          const wouldExceed = isAllowed && wouldExceedMaxOverlap(time);
-          
+
           const backgroundColor = (() => {
-            if (!isAllowed) return '#d1d5db'; // gray
-            if (wouldExceed) return '#fecaca'; // light red
             if (isInPreview) return '#60a5fa'; // blue
-            return heatmapColorFunc(overlaps, 0, 30);
+            if (!slot) return '#d1d5db'; // gray
+            // the fuller the slot, the hotter
+            return heatmapColorFunc(maxRemaining - slot.remaining, 0, maxRemaining);
           })();
-          
+
           return (
             <div
               key={time}
               className={`
                 h-8 relative flex items-center ml-16
-                ${isAllowed && !wouldExceed ? 'cursor-pointer' : 'cursor-not-allowed'}
+                ${slot ? 'cursor-pointer' : 'cursor-not-allowed'}
               `}
               style={{ backgroundColor, gridRow: i + 1, gridColumn: 1 }}
+              title={slot ? `${slot.remaining} seats left` : undefined}
               onMouseEnter={() => {
                 setHoverTime(time);
-                if (wouldExceed) {
-                  setError(`Scheduling here would exceed maximum overlap`);
-                } else {
-                  setError(null);
-                }
+                setError(null);
               }}
               onMouseLeave={() => {
                 setHoverTime(null);
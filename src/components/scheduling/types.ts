@@ -1,15 +1,11 @@
-
 export interface TimeWindow {
   start: number; // minutes from midnight
   end: number;   // minutes from midnight
 }
 
-export interface Scheduling extends TimeWindow {
-  weight: number;
-}
-
-export interface Availability extends TimeWindow {
-  seats: number;
+export interface Slot {
+  start: number; // minutes from midnight
+  remaining: number; // seats left for the whole test
 }
 
 export interface OwnedScheduling extends TimeWindow {
@@ -18,19 +14,14 @@ export interface OwnedScheduling extends TimeWindow {
 
 export interface SchedulingTimelineProps {
   title: string;
-  allowedWindows: Availability[];
-  existingSchedules: Scheduling[];
+  slots: Slot[];
   mySchedulings: OwnedScheduling[];
   duration: number;
   timelineStartMins?: number | undefined;
   timelineEndMins?: number | undefined;
   cellDurationMins?: number | undefined;
   heatmapColorFunc?: HeatmapColorFunc | undefined;
-  update: (slot: {startMins: number, durationMins: number}) => void;
-}
-export interface UpdateBits {
-  startMins: number;
-  durationMins: number;
+  update: (startMins: number) => void;
 }
 
 export type HeatmapColorFunc = (value: number, min: number, max: number) => string;
@@ -25,132 +25,30 @@ export function fetchForward(
   });
 }
 
-export async function* paginatedCanvasRequest<T>(
-  initialRequest: FetchForwardRequest,
-  transformer: (response: FetchForwardResponse) => T[] | Promise<T[]>
-) {
-  let next: FetchForwardRequest | null = initialRequest;
-  while (next != null) {
-    console.log(next.url);
-    const response = await fetchForward(next);
-    let nextUrl = null;
-    for (const header in response.headers) {
-      if (header.toLowerCase() === "link") {
-        const linkHeaderParser = /<([^>]+)>; rel=\"([^\"]+)\",?/g;
-        for (const link of response.headers[header]) {
-          let match;
-          while ((match = linkHeaderParser.exec(link)) !== null) {
-            if (match[2] === "next") {
-              nextUrl = match[1];
-              break;
-            }
-          }
-        }
-        break;
-      }
-    }
-    next =
-      nextUrl != null
-        ? {
-            method: "GET",
-            url: nextUrl,
-            body: "",
-            headers: next.headers,
-          }
-        : null;
-    for (const value of await transformer(response)) {
-      yield value;
-    }
-  }
-}
-
-export interface CanvasStudent {
-  id: string;
+export interface RosterStudent {
+  student: number;
   name: string;
+  enrollment: string;
 }
 
-export async function retrieveCanvasStudents(
-  authHeader: string,
-  courseId: string
-): Promise<CanvasStudent[]> {
-  const request = paginatedCanvasRequest(
-    {
-      method: "GET",
-      url: new URL(
-        `courses/${courseId}/users?enrollment_type=student`,
-        API_BASE
-      ).href,
-      headers: { Authorization: authHeader },
-      body: "",
-    },
-    (res) => {
-      console.log(res);
-      return JSON.parse(res.body ?? "[]").map((s: any) => ({
-        id: s.id,
-        name: s.name,
-      })) as CanvasStudent[];
-    }
-  );
-  const result = [];
-  for await (const student of request) result.push(student);
-  return result;
+export interface RosterSync {
+  added: RosterStudent[];
+  renamed: RosterStudent[];
+  removed: RosterStudent[];
+  failed: { student: number; name: string; reason: string }[];
 }
 
-export async function createEnrollmentsForStudents(
+// Enrolls the students of the Canvas course in the test on the server, which
+// holds the Canvas token
+export function syncTestRoster(
   testId: string,
-  students: CanvasStudent[],
-  unlocksAfter: string
-) {
-  const collection = pocketBase.collection("test_enrollments");
-  const result = { new: 0, existing: 0 };
-
-  // Get all student IDs
-  const studentIds = students.map((student) => student.id);
-
-  // Fetch all existing enrollments for this test in one batch query
-  const existingEnrollments = await collection.getFullList({
-    filter: pocketBase.filter(
-      "test = {:test} && (" +
-        students
-          .map((student) => "canvas_student_id = " + student.id)
-          .join(" || ") +
-        ")",
-      {
-        test: testId,
-        studentIds: studentIds,
-      }
-    ),
-  });
-
-  // Create a map of existing enrollments by student ID for quick lookup
-  const existingEnrollmentMap = new Map();
-  existingEnrollments.forEach((enrollment) => {
-    existingEnrollmentMap.set(
-      enrollment.canvas_student_id.toString(),
-      enrollment
-    );
+  courseId: string,
+  unlockAfter: string
+): Promise<RosterSync> {
+  return pocketBase.send(`/api/tests/${testId}/sync-roster`, {
+    method: "POST",
+    body: { courseId, unlockAfter },
   });
-
-  // Process each student
-  for (const student of students) {
-    // Skip if enrollment already exists
-    if (existingEnrollmentMap.has(student.id.toString())) {
-      result.existing++;
-      continue;
-    }
-
-    // Create new enrollment
-    await collection.create({
-      test: testId,
-      canvas_student_id: student.id,
-      canvas_student_name: student.name,
-      unlocks_at: unlocksAfter,
-    });
-
-    result.new++;
-  }
-
-  return result;
 }
 
 export async function sendLinksToStudents(
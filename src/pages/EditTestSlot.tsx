@@ -1,17 +1,16 @@
 import { parsePocketbaseDate } from "@/lib/utils";
 import { pocketBase } from "@/pocketbase";
-import { RecordModel } from "pocketbase";
+import { ClientResponseError, RecordModel } from "pocketbase";
 import { useEffect, useMemo, useState } from "react";
 import { useParams } from "react-router";
 
 import {
-  Availability,
   OwnedScheduling,
-  Scheduling,
+  Slot,
   TimeWindow,
 } from "@/components/scheduling/types";
 import VerticalSchedulingTimeline from "@/components/scheduling/VerticalSchedulingTimeline";
-import { millisecondsInDay, millisecondsInMinute } from "date-fns/constants";
+import { millisecondsInMinute } from "date-fns/constants";
 import { Button } from "@/components/ui/button";
 
 export interface TestEnrollment extends RecordModel {
@@ -30,14 +29,21 @@ export interface Test extends RecordModel {
   closes: string;
 }
 
-export interface TestingCenterHours extends RecordModel {
-  opens: string;
-  closes: string;
-  seats: number;
+/** Response of GET /api/availability/{enrollmentId} */
+export interface Availability {
+  enrollment: string;
+  durationMins: number;
+  granularityMins: number;
+  slots: { start: string; end: string; remaining: number }[];
 }
 
 export const TestEnrollments = pocketBase.collection("test_enrollments");
-export const TestingCenterHours = pocketBase.collection("testing_center_hours");
+
+/** Minutes between the start times offered, one timeline cell each */
+const SLOT_GRANULARITY_MINS = 10;
+
+/** How often the start times offered are refetched for other bookings */
+const AVAILABILITY_POLL_MS = 30 * 1000;
 
 export default function EditTestSlotPage() {
   const params = useParams<"enrollmentId">();
@@ -70,155 +76,48 @@ export default function EditTestSlotPage() {
     };
   }, [params.enrollmentId]);
 
-  var openValue: Date | null = null;
-  var closeValue: Date | null = null;
-  if (enrollment != null) {
-    openValue = parsePocketbaseDate(enrollment.expand.test.opens);
-    if (enrollment.unlocks_at != null && enrollment.unlocks_at !== "") {
-      const unlocksAt = parsePocketbaseDate(enrollment.unlocks_at)!;
-      if (unlocksAt > openValue!) openValue = unlocksAt;
-    }
-    closeValue = parsePocketbaseDate(enrollment.expand.test.closes);
-  }
-
   interface Desired {
     startAtDate: number;
-    minutes: number;
-    maxMinutes: number;
   }
   const [desiredScheduling, setDesiredScheduling] = useState<Desired | null>(
     null
   );
+  const [submitError, setSubmitError] = useState<string | null>(null);
 
-  const [availability, setAvailability] = useState<TestingCenterHours[]>([]);
+  // The server works out which start times leave a seat for the whole test,
+  // by the same rule it checks bookings with, so refetch whenever hours
+  // change. Students can't subscribe to other students' bookings, since only
+  // staff can list enrollments, so those are picked up by polling
+  const [availability, setAvailability] = useState<Availability | null>(null);
+  const enrollmentId = params.enrollmentId;
   useEffect(() => {
-    setAvailability([]);
-    const myOpenValue = openValue;
-    const myCloseValue = closeValue;
-    if (myOpenValue == null || myCloseValue == null) return;
+    setAvailability(null);
+    if (typeof enrollmentId !== "string") return;
 
-    const selectedHours = new Map<string, TestingCenterHours>();
-    TestingCenterHours.getList(1, 1000, {
-      filter: pocketBase.filter(
-        "(opens <= {:myOpenValue} && closes >= {:myOpenValue}) || (opens <= {:myCloseValue} && closes >= {:myCloseValue}) || ({:myOpenValue} <= opens && {:myCloseValue} >= opens) || ({:myOpenValue} <= closes && {:myCloseValue} >= closes)",
-        { myOpenValue, myCloseValue }
-      ),
-      sort: "opens",
-    }).then((result) => {
-      for (const item of result.items) {
-        selectedHours.set(item.id, item as TestingCenterHours);
-      }
-      if (result.items.length > 0)
-        setAvailability(Array.from(selectedHours.values()));
-    });
-    const subscription = TestingCenterHours.subscribe<TestingCenterHours>(
-      "*",
-      (event) => {
-        if (event.action === "delete" && selectedHours.delete(event.record.id))
-          setAvailability(Array.from(selectedHours.values()));
-        else {
-          const opens = parsePocketbaseDate(event.record.opens)!;
-          const closes = parsePocketbaseDate(event.record.closes)!;
-          const relevant =
-            (opens <= myOpenValue && closes >= myOpenValue) ||
-            (opens <= myCloseValue && closes >= myCloseValue) ||
-            (myOpenValue <= opens && myCloseValue >= opens) ||
-            (myOpenValue <= closes && myCloseValue >= closes);
-          var updated = false;
-          if (relevant) {
-            selectedHours.set(event.record.id, event.record);
-            updated = true;
-          } else {
-            updated = selectedHours.delete(event.record.id);
-          }
-          if (updated) setAvailability(Array.from(selectedHours.values()));
-        }
-      }
-    );
-    return () => {
-      subscription.then((unsubscribe) => unsubscribe());
-    };
-  }, [openValue?.valueOf(), closeValue?.valueOf()]);
-
-  interface MyScheduling extends Scheduling {
-    day: number;
-  }
-  const [existing, setExisting] = useState<MyScheduling[]>([]);
-  useEffect(() => {
-    const myOpenValue = openValue as Date;
-    const myCloseValue = closeValue as Date;
-    setExisting([]);
-    if (myOpenValue == null || myCloseValue == null) return;
-
-    const tracked = new Map<string, MyScheduling>();
-    function entryForEnrollment(
-      enrollment: TestEnrollment
-    ): MyScheduling | null {
-      if (enrollment.start_test_at == null || enrollment.start_test_at === "")
-        return null;
-      const opens = parsePocketbaseDate(enrollment.start_test_at)!;
-      const closes = new Date(
-        opens.valueOf() + enrollment.duration_mins * millisecondsInMinute
-      );
-      const relevant =
-        (opens <= myOpenValue && closes >= myOpenValue) ||
-        (opens <= myCloseValue && closes >= myCloseValue) ||
-        (myOpenValue <= opens && myCloseValue >= opens) ||
-        (myOpenValue <= closes && myCloseValue >= closes);
-      if (!relevant) return null;
-      const day = new Date(opens.valueOf());
-      day.setHours(0);
-      day.setMinutes(0);
-      day.setSeconds(0);
-      day.setMilliseconds(0);
-      const startMins =
-        (opens.valueOf() - day.valueOf()) / millisecondsInMinute;
-      return {
-        day: day.valueOf(),
-        start: startMins,
-        end: startMins + enrollment.duration_mins,
-        weight: 1,
-      };
+    let cancelled = false;
+    function refresh() {
+      pocketBase
+        .send<Availability>(`/api/availability/${enrollmentId}`, {
+          query: { granularity: SLOT_GRANULARITY_MINS },
+          requestKey: null,
+        })
+        .then((result) => {
+          if (!cancelled) setAvailability(result);
+        })
+        .catch(console.error);
     }
-    const filterOpenValue = new Date(myOpenValue.valueOf() - millisecondsInDay);
-    const filterCloseValue = new Date(
-      myCloseValue.valueOf() + millisecondsInDay
-    );
-    TestEnrollments.getList<TestEnrollment>(1, 100000, {
-      filter: pocketBase.filter(
-        "{:myOpenValue} <= start_test_at && {:myCloseValue} >= start_test_at",
-        { myOpenValue: filterOpenValue, myCloseValue: filterCloseValue }
-      ),
-    }).then((result) => {
-      console.log("Initial enrollments: " + result.items.length);
-      for (const item of result.items) {
-        const entry = entryForEnrollment(item);
-        if (entry != null) tracked.set(item.id, entry);
-      }
-      setExisting(Array.from(tracked.values()));
-    });
+    refresh();
 
-    const subscription = TestEnrollments.subscribe<TestEnrollment>(
-      "*",
-      (event) => {
-        if (event.action === "delete" && tracked.delete(event.record.id))
-          setExisting(Array.from(tracked.values()));
-        else {
-          var updated = false;
-          const entry = entryForEnrollment(event.record);
-          if (entry == null) updated = tracked.delete(event.record.id);
-          else {
-            tracked.set(event.record.id, entry);
-            updated = true;
-          }
-          if (updated) setExisting(Array.from(tracked.values()));
-        }
-      }
-    );
+    const interval = setInterval(refresh, AVAILABILITY_POLL_MS);
+    const subscription = pocketBase
+      .collection("testing_center_hours")
+      .subscribe("*", refresh);
     return () => {
+      cancelled = true;
+      clearInterval(interval);
       subscription.then((unsubscribe) => unsubscribe());
     };
-  }, [openValue?.valueOf(), closeValue?.valueOf()]);
+  }, [enrollmentId]);
 
   function mintSchedulings<T>(
     startDate: Date,
@@ -254,14 +153,21 @@ export default function EditTestSlotPage() {
   }
 
   const timeDetails = useMemo(() => {
-    const days = new Map<number, Availability[]>();
-    for (const hours of availability) {
-      mintSchedulings(
-        parsePocketbaseDate(hours.opens)!,
-        parsePocketbaseDate(hours.closes)!,
-        days,
-        (window) => ({ ...window, seats: hours.seats })
-      );
+    const days = new Map<number, Slot[]>();
+    for (const slot of availability?.slots ?? []) {
+      const start = parsePocketbaseDate(slot.start)!;
+      const day = new Date(start.valueOf());
+      day.setHours(0, 0, 0, 0);
+
+      var thisDay = days.get(day.valueOf());
+      if (thisDay == null) {
+        thisDay = [];
+        days.set(day.valueOf(), thisDay);
+      }
+      thisDay.push({
+        start: (start.valueOf() - day.valueOf()) / millisecondsInMinute,
+        remaining: slot.remaining,
+      });
     }
     return Array.from(days.entries());
   }, [availability]);
@@ -282,11 +188,11 @@ export default function EditTestSlotPage() {
         confirmed: true,
       }));
     }
-    if (desiredScheduling != null) {
+    if (desiredScheduling != null && availability != null) {
       const start = new Date(desiredScheduling.startAtDate);
       const end = new Date(
         desiredScheduling.startAtDate +
-          desiredScheduling.minutes * millisecondsInMinute
+          availability.durationMins * millisecondsInMinute
       );
       mintSchedulings(start, end, mySchedulings, (window) => ({
         ...window,
@@ -298,33 +204,23 @@ export default function EditTestSlotPage() {
     enrollment?.start_test_at,
     enrollment?.duration_mins,
     desiredScheduling?.startAtDate,
-    desiredScheduling?.minutes,
+    availability?.durationMins,
   ]);
 
-  const existingsGroups = useMemo(() => {
-    const groups = new Map<number, MyScheduling[]>();
-    for (const entry of existing) {
-      var thisDay = groups.get(entry.day);
-      if (thisDay == null) {
-        thisDay = [];
-        groups.set(entry.day, thisDay);
-      }
-      thisDay.push(entry);
-    }
-    return groups;
-  }, [existing]);
-
   function submitDesiredInput() {
     if (desiredScheduling === null) return;
-    console.log(
-      pocketBase.filter("{:date}", {
-        date: new Date(desiredScheduling.startAtDate),
-      })
-    );
+    setSubmitError(null);
+    // the duration comes from the test and the student's accommodations
     TestEnrollments.update(params.enrollmentId!!, {
       start_test_at: new Date(desiredScheduling.startAtDate),
-      duration_mins: desiredScheduling.minutes,
-    }).then(() => setDesiredScheduling(null));
+    })
+      .then(() => setDesiredScheduling(null))
+      .catch((e: ClientResponseError) => {
+        setSubmitError(
+          e.response?.data?.start_test_at?.message ??
+            "Couldn't book this time. Please pick another."
+        );
+      });
   }
 
   if (enrollment === null) return <main>Loading...</main>;
@@ -364,23 +260,20 @@ export default function EditTestSlotPage() {
               </h1>
               <p>
                 {formatDate(new Date(desiredScheduling.startAtDate))}
-                <br />
-                Duration:{" "}
-                <input
-                  value={desiredScheduling.minutes}
-                  onChange={(e) => {
-                    const parsed = parseInt(e.target.value);
-                    if (isNaN(parsed)) return;
-                    setDesiredScheduling({
-                      ...desiredScheduling,
-                      minutes: Math.min(parsed, desiredScheduling.maxMinutes),
-                    });
-                  }}
-                  className="w-8 border border-black"
-                />{" "}
-                Minutes
+                {availability != null ? (
+                  <>
+                    <br />
+                    Duration: {formatDuration(availability.durationMins)}
+                  </>
+                ) : null}
                 <br />
                 <Button onClick={submitDesiredInput}>Submit</Button>
+                {submitError != null ? (
+                  <>
+                    <br />
+                    <span className="text-red-700">{submitError}</span>
+                  </>
+                ) : null}
               </p>
             </div>
           ) : null}
@@ -394,17 +287,16 @@ export default function EditTestSlotPage() {
                 title={`${DAYS[date.getDay()]}, ${
                   MONTHS[date.getMonth()]
                 } ${date.getDate()}`}
-                allowedWindows={day[1]}
-                existingSchedules={existingsGroups.get(day[0]) ?? []}
-                duration={enrollment.expand.test.duration_mins}
+                slots={day[1]}
+                duration={availability!.durationMins}
+                cellDurationMins={availability!.granularityMins}
                 mySchedulings={ownedSchedulings.get(day[0]) ?? []}
-                update={(bits) =>
+                update={(startMins) => {
+                  setSubmitError(null);
                   setDesiredScheduling({
-                    startAtDate: day[0] + bits.startMins * millisecondsInMinute,
-                    minutes: bits.durationMins,
-                    maxMinutes: bits.durationMins,
-                  })
-                }
+                    startAtDate: day[0] + startMins * millisecondsInMinute,
+                  });
+                }}
               />
             );
           })}
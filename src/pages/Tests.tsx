@@ -35,11 +35,7 @@ import {
 import { Textarea } from "@/components/ui/textarea";
 import { Progress } from "@/components/ui/progress";
 import { pocketBase } from "@/pocketbase";
-import {
-  retrieveCanvasStudents,
-  createEnrollmentsForStudents,
-  sendLinksToStudents,
-} from "@/lib/canvas-utils";
+import { syncTestRoster, sendLinksToStudents } from "@/lib/canvas-utils";
 
 interface Test {
   id: string;
@@ -378,36 +374,21 @@ function GenerateAndSendLinksDialog({ tests }: { tests: Test[] }) {
     try {
       setIsLoading(true);
       setProgress(0);
-      setCurrentStep(0);
-      setTotalSteps(2);
-      setStatusMessage("Fetching students from Canvas...");
-
-      // Step 1: Retrieve students from Canvas
-      const students = await retrieveCanvasStudents(
-        "Bearer " + formData.authHeader,
-        formData.courseId
-      );
-
-      if (students.length === 0) {
-        throw new Error("No students found for this course");
-      }
-
       setCurrentStep(1);
-      setStatusMessage(
-        `Signing ${students.length} students up for the test...`
-      );
+      setTotalSteps(2);
+      setStatusMessage("Signing the course's students up for the test...");
 
-      // Step 2: Create enrollments for the students
-      await createEnrollmentsForStudents(
+      // Step 1: Enroll the course's students
+      const roster = await syncTestRoster(
         formData.testId,
-        students,
+        formData.courseId,
         formData.unlocksAfter
       );
 
       setCurrentStep(2);
       setStatusMessage("Sending links to students...");
 
-      // Step 3: Send links to the students
+      // Step 2: Send links to the students
       const { sent, total } = await sendLinksToStudents(
         "Bearer " + formData.authHeader,
         formData.testId,
@@ -419,8 +400,10 @@ function GenerateAndSendLinksDialog({ tests }: { tests: Test[] }) {
         }
       );
 
+      const failed = roster.failed.map((student) => student.name).join(", ");
       setStatusMessage(
-        `Successfully sent ${sent} links out of ${total} students`
+        `Successfully sent ${sent} links out of ${total} students` +
+          (failed !== "" ? `. Couldn't sign up: ${failed}` : "")
       );
       setTimeout(() => {
         setOpen(false);
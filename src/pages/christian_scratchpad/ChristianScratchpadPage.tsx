@@ -9,10 +9,7 @@ export function ChristianScratchpadLayout() {
       <DebuggingStuff />
       <div className="flex flex-col gap-4">
         <div>
-          <NavLink to="/christian_scratchpad/test_enrollment_fabricator">
-            Test Enrollment Fabricator
-          </NavLink>{" "}
-          |<NavLink to="/christian_scratchpad/link_sender">Link Sender</NavLink>{" "}
+          <NavLink to="/christian_scratchpad/link_sender">Link Sender</NavLink>{" "}
           |
           <NavLink to="/christian_scratchpad/crude_scheduling_exporter">
             Crude Scheduling Exporter